@@ -43,7 +43,15 @@ func (a *Analyzer) GetMetadata(newRepoStore func(string) (img.Store, error), rep
 	}
 
 	origImage, err := repoStore.Image()
-	if err != nil {
+	switch err.(type) {
+	case nil:
+	case *img.NotFoundError:
+		fmt.Fprintf(a.Out, "WARNING: skipping analyze, previous image not found: %s", err.Error())
+		return "", nil
+	case *img.LayoutError:
+		fmt.Fprintf(a.Out, "WARNING: skipping analyze, reading image layout failed: %s", err.Error())
+		return "", nil
+	default:
 		fmt.Fprintf(a.Out, "WARNING: skipping analyze, authenticating to registry failed: %s", err.Error())
 		return "", nil
 	}
@@ -210,6 +210,34 @@ func testAnalyzer(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("the previous image is not in the layout", func() {
+			it.Before(func() {
+				repoStore.EXPECT().Image().Return(nil, &img.NotFoundError{Tag: "my_org/my_repo", Path: "some/layout"})
+			})
+			it("warns user that there is no previous image and returns", func() {
+				metadata, err := analyzer.GetMetadata(newRepoStore, "my_org/my_repo")
+				assertNil(t, err)
+				assertEq(t, metadata, "")
+				if !strings.Contains(stdout.String(), "WARNING: skipping analyze, previous image not found: image 'my_org/my_repo' not found in layout 'some/layout'") {
+					t.Fatalf("expected warning in stdout: %s", stdout.String())
+				}
+			})
+		})
+
+		when("the layout cannot be read", func() {
+			it.Before(func() {
+				repoStore.EXPECT().Image().Return(nil, &img.LayoutError{Path: "some/layout", Err: errors.New("MyError")})
+			})
+			it("warns user with a layout error and returns", func() {
+				metadata, err := analyzer.GetMetadata(newRepoStore, "my_org/my_repo")
+				assertNil(t, err)
+				assertEq(t, metadata, "")
+				if !strings.Contains(stdout.String(), "WARNING: skipping analyze, reading image layout failed: read OCI image layout 'some/layout': MyError") {
+					t.Fatalf("expected warning in stdout: %s", stdout.String())
+				}
+			})
+		})
+
 		when("using a registry #Image returns but #RawManifest has errors", func() {
 			it.Before(func() {
 				repoStore.EXPECT().Image().Return(image, nil)
@@ -2,12 +2,14 @@ package lifecycle
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -20,18 +22,27 @@ type Builder struct {
 	Env         BuildEnv
 	Buildpacks  []*Buildpack
 	Plan        Plan
+	// Merge combines the bill-of-materials entries written by buildpacks
+	// with the plan entries they consume.
+	Merge PlanMerge
+	// PlatformEnv, if set, adds the platform env directory to Env.
+	PlatformEnv *PlatformEnv
 	Out, Err    io.Writer
 }
 
 type BuildEnv interface {
 	AddRootDir(baseDir string) error
 	AddEnvDir(envDir string) error
+	AddFilteredEnvDir(envDir string, keep func(name string) bool) error
 	List() []string
 }
 
 type Process struct {
 	Type    string `toml:"type"`
 	Command string `toml:"command"`
+	// Args and Direct are used to run Command without a shell.
+	Args   []string `toml:"args,omitempty"`
+	Direct bool     `toml:"direct,omitempty"`
 }
 
 type LaunchTOML struct {
@@ -47,6 +58,10 @@ type BuildMetadata struct {
 }
 
 func (b *Builder) Build() (*BuildMetadata, error) {
+	return b.run("build")
+}
+
+func (b *Builder) run(executable string) (*BuildMetadata, error) {
 	platformDir, err := filepath.Abs(b.PlatformDir)
 	if err != nil {
 		return nil, err
@@ -69,6 +84,12 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 	}
 	defer os.RemoveAll(planDir)
 
+	if b.PlatformEnv != nil {
+		if err := b.PlatformEnv.AddTo(b.Env, platformDir); err != nil {
+			return nil, err
+		}
+	}
+
 	procMap := processMap{}
 	plan := copyPlan(b.Plan)
 	bom := copyPlan(b.Plan)
@@ -92,7 +113,7 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 		if err := toml.NewEncoder(planIn).Encode(plan); err != nil {
 			return nil, err
 		}
-		buildPath, err := filepath.Abs(filepath.Join(bp.Dir, "bin", "build"))
+		buildPath, err := filepath.Abs(filepath.Join(bp.Dir, "bin", executable))
 		if err != nil {
 			return nil, err
 		}
@@ -108,9 +129,16 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 		if err := setupEnv(b.Env, bpCacheDir); err != nil {
 			return nil, err
 		}
-		if err := consumePlan(bpPlanDir, plan, bom); err != nil {
+		conflicts, err := consumePlan(bpPlanDir, plan, bom, b.Merge)
+		if err != nil {
 			return nil, err
 		}
+		if len(conflicts) > 0 && b.Merge == MergeDeepStrict {
+			return nil, fmt.Errorf("buildpack '%s' has plan conflicts: %s", bp.ID, strings.Join(conflicts, "; "))
+		}
+		for _, conflict := range conflicts {
+			fmt.Fprintf(b.Err, "Warning: %s plan conflict: %s\n", bp.ID, conflict)
+		}
 		var launch LaunchTOML
 		tomlPath := filepath.Join(bpLaunchDir, "launch.toml")
 		if _, err := toml.DecodeFile(tomlPath, &launch); os.IsNotExist(err) {
@@ -139,7 +167,10 @@ func setupEnv(env BuildEnv, cacheDir string) error {
 		return err
 	}
 	return eachDir(cacheFiles, func(layer os.FileInfo) error {
-		return env.AddEnvDir(filepath.Join(cacheDir, layer.Name(), "env"))
+		if err := env.AddEnvDir(filepath.Join(cacheDir, layer.Name(), "env")); err != nil {
+			return err
+		}
+		return env.AddEnvDir(filepath.Join(cacheDir, layer.Name(), "env.build"))
 	})
 }
 
@@ -155,10 +186,10 @@ func eachDir(files []os.FileInfo, fn func(os.FileInfo) error) error {
 	return nil
 }
 
-func consumePlan(planDir string, plan, bom Plan) error {
+func consumePlan(planDir string, plan, bom Plan, merge PlanMerge) (conflicts []string, err error) {
 	files, err := ioutil.ReadDir(planDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, f := range files {
 		if f.IsDir() {
@@ -167,14 +198,14 @@ func consumePlan(planDir string, plan, bom Plan) error {
 		path := filepath.Join(planDir, f.Name())
 		var entry map[string]interface{}
 		if _, err := toml.DecodeFile(path, &entry); err != nil {
-			return err
+			return nil, err
 		}
 		delete(plan, f.Name())
 		if len(entry) > 0 {
-			bom[f.Name()] = entry
+			conflicts = append(conflicts, merge.mergeEntry(bom, f.Name(), entry)...)
 		}
 	}
-	return nil
+	return conflicts, nil
 }
 
 type processMap map[string]Process
@@ -198,10 +229,12 @@ func (m processMap) list() []Process {
 	return procs
 }
 
+// copyPlan copies nested tables and arrays as well, so that merging into
+// the copy does not modify the original plan.
 func copyPlan(m Plan) Plan {
 	out := Plan{}
 	for k, v := range m {
-		out[k] = v
+		out[k], _ = copyValue(map[string]interface{}(v)).(map[string]interface{})
 	}
 	return out
 }
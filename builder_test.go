@@ -107,12 +107,16 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 					env.EXPECT().AddRootDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer1")),
 					env.EXPECT().AddRootDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer2")),
 					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer1", "env")),
+					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer1", "env.build")),
 					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer2", "env")),
+					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer2", "env.build")),
 
 					env.EXPECT().AddRootDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer3")),
 					env.EXPECT().AddRootDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer4")),
 					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer3", "env")),
+					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer3", "env.build")),
 					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer4", "env")),
+					env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer4", "env.build")),
 				)
 				if _, err := builder.Build(); err != nil {
 					t.Fatalf("Error: %s\n", err)
@@ -182,6 +186,45 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should merge bill-of-materials entries into plan entries when deep merge is used", func() {
+				builder.Merge = lifecycle.MergeDeep
+				mkfile(t, "v = \"7\"\nreplace = true", filepath.Join(appDir, "dep-replace"))
+				metadata, err := builder.Build()
+				if err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(metadata.BOM, lifecycle.Plan{
+					"dep1":         {"v": "1"},
+					"dep1-keep":    {"v": "2"},
+					"dep1-replace": {"v": "7", "replace": true},
+					"dep2":         {"v": "4"},
+					"dep2-keep":    {"v": "5"},
+					"dep2-replace": {"v": "7", "replace": true},
+				}); s != "" {
+					t.Fatalf("Unexpected BOM:\n%s\n", s)
+				}
+				if !strings.Contains(stderr.String(), "Warning: buildpack1-id plan conflict: 'dep1-replace.v' is both 3 and 7\n") {
+					t.Fatalf("Unexpected stderr: %s\n", stderr)
+				}
+				if s := cmp.Diff(builder.Plan["dep1-replace"], map[string]interface{}{"v": "3"}); s != "" {
+					t.Fatalf("Unexpected plan modification:\n%s\n", s)
+				}
+			})
+
+			it("should add the platform env dir to the env when requested", func() {
+				builder.PlatformEnv = &lifecycle.PlatformEnv{Allow: []string{"SOME_VAR"}}
+				env.EXPECT().AddFilteredEnvDir(filepath.Join(platformDir, "env"), gomock.Any()).Do(
+					func(_ string, keep func(string) bool) {
+						if !keep("SOME_VAR") || keep("OTHER_VAR") {
+							t.Fatal("Unexpected filter.\n")
+						}
+					},
+				)
+				if _, err := builder.Build(); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+			})
+
 			it("should provide the platform dir", func() {
 				mkfile(t, "some-data",
 					filepath.Join(platformDir, "env", "SOME_VAR"),
@@ -270,6 +313,17 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should error when a bill-of-materials entry conflicts with strict deep merge", func() {
+				env.EXPECT().List().Return([]string{"ID=1"})
+				builder.Merge = lifecycle.MergeDeepStrict
+				mkfile(t, "v = \"7\"", filepath.Join(appDir, "dep-replace"))
+				if _, err := builder.Build(); err == nil {
+					t.Fatal("Expected error.\n")
+				} else if !strings.Contains(err.Error(), "'dep1-replace.v' is both 3 and 7") {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+
 			it("should error when the command fails", func() {
 				env.EXPECT().List().Return([]string{"ID=1"})
 				if err := os.RemoveAll(platformDir); err != nil {
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/img"
+)
+
+type ImageCache struct {
+	store     img.Store
+	origImage v1.Image
+}
+
+func NewImageCache(store img.Store) *ImageCache {
+	return &ImageCache{store: store}
+}
+
+func (c *ImageCache) RetrieveMetadata() (lifecycle.CacheMetadata, error) {
+	var metadata lifecycle.CacheMetadata
+	image, err := c.image()
+	if err != nil {
+		return metadata, err
+	}
+	if image == nil {
+		return metadata, nil
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return metadata, errors.Wrap(err, "read cache image config")
+	}
+	label := configFile.Config.Labels[lifecycle.CacheMetadataLabel]
+	if label == "" {
+		return metadata, nil
+	}
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return metadata, errors.Wrap(err, "unmarshal cache metadata")
+	}
+	return metadata, nil
+}
+
+func (c *ImageCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	image, err := c.image()
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, fmt.Errorf("cache image '%s' does not exist", c.store.Ref())
+	}
+	hash, err := v1.NewHash(sha)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := image.LayerByDiffID(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "find layer %s", sha)
+	}
+	return layer.Uncompressed()
+}
+
+func (c *ImageCache) Commit(metadata lifecycle.CacheMetadata, artifactsDir string) error {
+	origImage, err := c.image()
+	if err != nil {
+		return err
+	}
+
+	image := empty.Image
+	for _, bpMetadata := range metadata.Buildpacks {
+		var layerNames []string
+		for layerName := range bpMetadata.Layers {
+			layerNames = append(layerNames, layerName)
+		}
+		sort.Strings(layerNames)
+		for _, layerName := range layerNames {
+			sha := bpMetadata.Layers[layerName].SHA
+			if layer, ok := c.origLayer(origImage, sha); ok {
+				image, err = mutate.AppendLayers(image, layer)
+				if err != nil {
+					return errors.Wrapf(err, "append layer %s/%s from previous cache image", bpMetadata.ID, layerName)
+				}
+				continue
+			}
+			tar := filepath.Join(artifactsDir, rawSHA(sha)+".tar")
+			if _, err := os.Stat(tar); err != nil {
+				return errors.Wrapf(err, "find tar for layer %s/%s", bpMetadata.ID, layerName)
+			}
+			image, _, err = img.Append(image, tar)
+			if err != nil {
+				return errors.Wrapf(err, "append new layer %s/%s", bpMetadata.ID, layerName)
+			}
+		}
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return errors.Wrap(err, "get encoded metadata")
+	}
+	image, err = img.Label(image, lifecycle.CacheMetadataLabel, string(metadataJSON))
+	if err != nil {
+		return errors.Wrap(err, "set metadata label")
+	}
+	if err := c.store.Write(image); err != nil {
+		return errors.Wrap(err, "write cache image")
+	}
+	c.origImage = image
+	return nil
+}
+
+func (c *ImageCache) image() (v1.Image, error) {
+	if c.origImage != nil {
+		return c.origImage, nil
+	}
+	image, err := c.store.Image()
+	if err != nil {
+		return nil, errors.Wrapf(err, "access cache image '%s'", c.store.Ref())
+	}
+	if _, err := image.RawManifest(); err != nil {
+		if remoteErr, ok := err.(*remote.Error); ok && len(remoteErr.Errors) > 0 {
+			switch remoteErr.Errors[0].Code {
+			case remote.ManifestUnknownErrorCode, remote.NameUnknownErrorCode:
+				return nil, nil
+			}
+		}
+		return nil, errors.Wrapf(err, "access cache image manifest '%s'", c.store.Ref())
+	}
+	c.origImage = image
+	return image, nil
+}
+
+func (c *ImageCache) origLayer(origImage v1.Image, sha string) (v1.Layer, bool) {
+	if origImage == nil {
+		return nil, false
+	}
+	hash, err := v1.NewHash(sha)
+	if err != nil {
+		return nil, false
+	}
+	layer, err := origImage.LayerByDiffID(hash)
+	if err != nil {
+		return nil, false
+	}
+	return layer, true
+}
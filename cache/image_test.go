@@ -0,0 +1,274 @@
+package cache_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cache"
+	"github.com/buildpack/lifecycle/img"
+)
+
+func TestImageCache(t *testing.T) {
+	spec.Run(t, "ImageCache", testImageCache, spec.Report(report.Terminal{}))
+}
+
+func testImageCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		registry     *fakeRegistry
+		server       *httptest.Server
+		imageCache   *cache.ImageCache
+		tmpDir       string
+		srcDir       string
+		artifactsDir string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.cache.image")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		srcDir = filepath.Join(tmpDir, "src")
+		artifactsDir = filepath.Join(tmpDir, "artifacts")
+		mkdir(t,
+			filepath.Join(srcDir, "buildpack1", "layer1"),
+			filepath.Join(srcDir, "buildpack2", "layer2"),
+			artifactsDir,
+		)
+		mkfile(t, "file1", filepath.Join(srcDir, "buildpack1", "layer1", "file1"))
+		mkfile(t, "file2", filepath.Join(srcDir, "buildpack2", "layer2", "file2"))
+		mkfile(t, `key = "val"`, filepath.Join(srcDir, "buildpack1", "layer1.toml"))
+
+		registry = newFakeRegistry()
+		server = httptest.NewServer(registry)
+		imageCache = newImageCache(t, server)
+	})
+
+	it.After(func() {
+		server.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	cacheLayers := func() {
+		t.Helper()
+		cacher := &lifecycle.Cacher{
+			Buildpacks:   []*lifecycle.Buildpack{{ID: "buildpack1"}, {ID: "buildpack2"}},
+			ArtifactsDir: artifactsDir,
+			Out:          ioutil.Discard,
+		}
+		if err := cacher.Cache(srcDir, imageCache); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	}
+
+	when("#RetrieveMetadata", func() {
+		it("should return empty metadata when the cache image does not exist", func() {
+			metadata, err := imageCache.RetrieveMetadata()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(metadata, lifecycle.CacheMetadata{}); s != "" {
+				t.Fatalf("Unexpected metadata:\n%s\n", s)
+			}
+		})
+
+		it("should return the metadata stored in the cache image label", func() {
+			cacheLayers()
+			metadata, err := newImageCache(t, server).RetrieveMetadata()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if len(metadata.Buildpacks) != 2 {
+				t.Fatalf("Unexpected metadata: %+v\n", metadata)
+			}
+			if s := cmp.Diff(metadata.Buildpacks[0].Layers["layer1"].Data, map[string]interface{}{"key": "val"}); s != "" {
+				t.Fatalf("Unexpected layer metadata:\n%s\n", s)
+			}
+		})
+	})
+
+	when("#Commit", func() {
+		it("should not push layers that are unchanged", func() {
+			cacheLayers()
+			pushed := registry.uploads()
+			if pushed == 0 {
+				t.Fatal("Expected layers to be pushed.\n")
+			}
+
+			mkfile(t, "new-file2", filepath.Join(srcDir, "buildpack2", "layer2", "file2"))
+			imageCache = newImageCache(t, server)
+			cacheLayers()
+
+			// one new layer and one new config
+			if s := cmp.Diff(registry.uploads()-pushed, 2); s != "" {
+				t.Fatalf("Unexpected uploads:\n%s\n", s)
+			}
+		})
+	})
+
+	when("#RetrieveLayer", func() {
+		it("should pull only the layers for buildpacks in the group", func() {
+			cacheLayers()
+			cacheDir := filepath.Join(tmpDir, "cache")
+			retriever := &lifecycle.Retriever{
+				Buildpacks: []*lifecycle.Buildpack{{ID: "buildpack1"}},
+				Out:        ioutil.Discard,
+				Err:        ioutil.Discard,
+			}
+			imageCache = newImageCache(t, server)
+			before := registry.blobPulls()
+			if err := retriever.Retrieve(cacheDir, imageCache); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+
+			if b, err := ioutil.ReadFile(filepath.Join(cacheDir, "buildpack1", "layer1", "file1")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			} else if s := cmp.Diff(string(b), "file1"); s != "" {
+				t.Fatalf("Unexpected file:\n%s\n", s)
+			}
+			if _, err := os.Stat(filepath.Join(cacheDir, "buildpack2")); !os.IsNotExist(err) {
+				t.Fatalf("Unexpected layer restored: %s\n", err)
+			}
+
+			// one config and one layer
+			if s := cmp.Diff(registry.blobPulls()-before, 2); s != "" {
+				t.Fatalf("Unexpected pulls:\n%s\n", s)
+			}
+		})
+	})
+}
+
+func newImageCache(t *testing.T, server *httptest.Server) *cache.ImageCache {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Error: %s\n", err)
+	}
+	store, err := img.NewRegistry(u.Host + "/some/cache:latest")
+	if err != nil {
+		t.Fatalf("Error: %s\n", err)
+	}
+	return cache.NewImageCache(store)
+}
+
+// fakeRegistry implements the subset of the registry API used by img.NewRegistry.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	pending   map[string][]byte
+	pushes    int
+	pulls     int
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		blobs:     map[string][]byte{},
+		manifests: map[string][]byte{},
+		pending:   map[string][]byte{},
+	}
+}
+
+func (r *fakeRegistry) uploads() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pushes
+}
+
+func (r *fakeRegistry) blobPulls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pulls
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := req.URL.Path
+	switch {
+	case path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(path, "/blobs/uploads/"):
+		r.serveUpload(w, req)
+	case strings.Contains(path, "/blobs/"):
+		digest := path[strings.LastIndex(path, "/")+1:]
+		blob, ok := r.blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.Method == http.MethodGet {
+			r.pulls++
+			w.Write(blob)
+		}
+	case strings.Contains(path, "/manifests/"):
+		if req.Method == http.MethodPut {
+			body, _ := ioutil.ReadAll(req.Body)
+			r.manifests[path] = body
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		manifest, ok := r.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors": [{"code": "MANIFEST_UNKNOWN"}]}`)
+			return
+		}
+		w.Write(manifest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *fakeRegistry) serveUpload(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		id := fmt.Sprintf("%d", len(r.pending))
+		r.pending[id] = nil
+		w.Header().Set("Location", req.URL.Path+id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPatch:
+		body, _ := ioutil.ReadAll(req.Body)
+		id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		r.pending[id] = append(r.pending[id], body...)
+		w.Header().Set("Location", req.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		r.blobs[req.URL.Query().Get("digest")] = r.pending[id]
+		r.pushes++
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func mkdir(t *testing.T, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	}
+}
+
+func mkfile(t *testing.T, data string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		if err := ioutil.WriteFile(p, []byte(data), 0777); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpack/lifecycle"
+)
+
+const volumeIndex = "index.json"
+
+type VolumeCache struct {
+	dir string
+}
+
+func NewVolumeCache(dir string) (*VolumeCache, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &VolumeCache{dir: dir}, nil
+}
+
+func (c *VolumeCache) RetrieveMetadata() (lifecycle.CacheMetadata, error) {
+	var metadata lifecycle.CacheMetadata
+	data, err := ioutil.ReadFile(filepath.Join(c.dir, volumeIndex))
+	if os.IsNotExist(err) {
+		return metadata, nil
+	} else if err != nil {
+		return metadata, err
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return metadata, err
+	}
+	return metadata, nil
+}
+
+func (c *VolumeCache) RetrieveLayer(sha string) (io.ReadCloser, error) {
+	return os.Open(c.layerPath(sha))
+}
+
+func (c *VolumeCache) Commit(metadata lifecycle.CacheMetadata, artifactsDir string) error {
+	keep := map[string]struct{}{volumeIndex: {}}
+	for _, bpMetadata := range metadata.Buildpacks {
+		for _, layer := range bpMetadata.Layers {
+			keep[filepath.Base(c.layerPath(layer.SHA))] = struct{}{}
+			if _, err := os.Stat(c.layerPath(layer.SHA)); err == nil {
+				continue
+			}
+			if err := c.copyLayer(filepath.Join(artifactsDir, rawSHA(layer.SHA)+".tar"), c.layerPath(layer.SHA)); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if err := c.writeFile(filepath.Join(c.dir, volumeIndex), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if _, ok := keep[f.Name()]; ok {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *VolumeCache) layerPath(sha string) string {
+	return filepath.Join(c.dir, rawSHA(sha)+".tar")
+}
+
+func (c *VolumeCache) copyLayer(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return c.writeFile(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+func (c *VolumeCache) writeFile(path string, fn func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(c.dir, ".tmp.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := fn(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func rawSHA(prefixedSHA string) string {
+	return strings.TrimPrefix(prefixedSHA, "sha256:")
+}
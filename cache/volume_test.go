@@ -0,0 +1,127 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cache"
+)
+
+func TestVolumeCache(t *testing.T) {
+	spec.Run(t, "VolumeCache", testVolumeCache, spec.Report(report.Terminal{}))
+}
+
+func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		volumeCache  *cache.VolumeCache
+		tmpDir       string
+		volumeDir    string
+		artifactsDir string
+		metadata     lifecycle.CacheMetadata
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.cache.volume")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		volumeDir = filepath.Join(tmpDir, "volume")
+		artifactsDir = filepath.Join(tmpDir, "artifacts")
+		mkdir(t, artifactsDir)
+		mkfile(t, "some-layer", filepath.Join(artifactsDir, "some-sha.tar"))
+		mkfile(t, "other-layer", filepath.Join(artifactsDir, "other-sha.tar"))
+		metadata = lifecycle.CacheMetadata{
+			Buildpacks: []lifecycle.BuildpackMetadata{{
+				ID:      "buildpack1",
+				Version: "1.0",
+				Layers: map[string]lifecycle.LayerMetadata{
+					"layer1": {SHA: "sha256:some-sha", Data: map[string]interface{}{"key": "val"}},
+				},
+			}},
+		}
+		volumeCache, err = cache.NewVolumeCache(volumeDir)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#RetrieveMetadata", func() {
+		it("should return empty metadata when the index is missing", func() {
+			actual, err := volumeCache.RetrieveMetadata()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(actual, lifecycle.CacheMetadata{}); s != "" {
+				t.Fatalf("Unexpected metadata:\n%s\n", s)
+			}
+		})
+
+		it("should return an error when the index is corrupt", func() {
+			mkfile(t, "{", filepath.Join(volumeDir, "index.json"))
+			if _, err := volumeCache.RetrieveMetadata(); err == nil {
+				t.Fatal("Expected error.\n")
+			}
+		})
+	})
+
+	when("#Commit", func() {
+		it("should save the metadata and layers for retrieval", func() {
+			if err := volumeCache.Commit(metadata, artifactsDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			actual, err := volumeCache.RetrieveMetadata()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(actual, metadata); s != "" {
+				t.Fatalf("Unexpected metadata:\n%s\n", s)
+			}
+			rc, err := volumeCache.RetrieveLayer("sha256:some-sha")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer rc.Close()
+			if b, err := ioutil.ReadAll(rc); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			} else if s := cmp.Diff(string(b), "some-layer"); s != "" {
+				t.Fatalf("Unexpected layer:\n%s\n", s)
+			}
+		})
+
+		it("should remove layers that are no longer in the metadata", func() {
+			if err := volumeCache.Commit(metadata, artifactsDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			metadata.Buildpacks[0].Layers["layer1"] = lifecycle.LayerMetadata{SHA: "sha256:other-sha"}
+			if err := volumeCache.Commit(metadata, artifactsDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if _, err := volumeCache.RetrieveLayer("sha256:some-sha"); !os.IsNotExist(err) {
+				t.Fatalf("Expected layer to be removed: %s\n", err)
+			}
+			files, err := ioutil.ReadDir(volumeDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name())
+			}
+			if s := cmp.Diff(names, []string{"index.json", "other-sha.tar"}); s != "" {
+				t.Fatalf("Unexpected files:\n%s\n", s)
+			}
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package lifecycle
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+type CacheStore interface {
+	RetrieveMetadata() (CacheMetadata, error)
+	RetrieveLayer(sha string) (io.ReadCloser, error)
+	Commit(metadata CacheMetadata, artifactsDir string) error
+}
+
+type Cacher struct {
+	Buildpacks   []*Buildpack
+	ArtifactsDir string
+	Out          io.Writer
+	UID, GID     int
+}
+
+func (c *Cacher) Cache(cacheDir string, cacheStore CacheStore) error {
+	var metadata CacheMetadata
+	for _, buildpack := range c.Buildpacks {
+		bpMetadata := BuildpackMetadata{ID: buildpack.ID, Version: buildpack.Version, Layers: make(map[string]LayerMetadata)}
+		bpCacheDir := filepath.Join(cacheDir, buildpack.EscapedID())
+		layers, err := ioutil.ReadDir(bpCacheDir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "read cache directory for buildpack '%s'", buildpack.ID)
+		}
+		if err := eachDir(layers, func(layer os.FileInfo) error {
+			var err error
+			var bpLayer LayerMetadata
+			layerDir := filepath.Join(bpCacheDir, layer.Name())
+			bpLayer.SHA, err = writeTar(c.ArtifactsDir, layerDir, filepath.Join(buildpack.EscapedID(), layer.Name()), c.UID, c.GID)
+			if err != nil {
+				return errors.Wrapf(err, "exporting tar for cache layer '%s/%s'", buildpack.ID, layer.Name())
+			}
+			var data map[string]interface{}
+			if _, err := toml.DecodeFile(layerDir+".toml", &data); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "read metadata for cache layer '%s/%s'", buildpack.ID, layer.Name())
+			}
+			bpLayer.Data = data
+			bpMetadata.Layers[layer.Name()] = bpLayer
+			fmt.Fprintf(c.Out, "caching layer '%s/%s' with SHA %s\n", buildpack.ID, layer.Name(), bpLayer.SHA)
+			return nil
+		}); err != nil {
+			return err
+		}
+		metadata.Buildpacks = append(metadata.Buildpacks, bpMetadata)
+	}
+	if err := cacheStore.Commit(metadata, c.ArtifactsDir); err != nil {
+		return errors.Wrap(err, "commit cache")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cache"
+)
+
+func TestCacher(t *testing.T) {
+	spec.Run(t, "Cacher", testCacher, spec.Report(report.Terminal{}))
+}
+
+func testCacher(t *testing.T, when spec.G, it spec.S) {
+	var (
+		cacher      *lifecycle.Cacher
+		volumeCache *cache.VolumeCache
+		stdout      *bytes.Buffer
+		tmpDir      string
+		cacheDir    string
+		volumeDir   string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.cacher")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		stdout = &bytes.Buffer{}
+		cacheDir = filepath.Join(tmpDir, "cache")
+		volumeDir = filepath.Join(tmpDir, "volume")
+		mkdir(t, filepath.Join(tmpDir, "artifacts"))
+		volumeCache, err = cache.NewVolumeCache(volumeDir)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		cacher = &lifecycle.Cacher{
+			Buildpacks: []*lifecycle.Buildpack{
+				{ID: "buildpack/1"},
+				{ID: "buildpack2"},
+			},
+			ArtifactsDir: filepath.Join(tmpDir, "artifacts"),
+			Out:          io.MultiWriter(stdout, it.Out()),
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#Cache", func() {
+		it.Before(func() {
+			mkdir(t,
+				filepath.Join(cacheDir, "buildpack_1", "layer1"),
+				filepath.Join(cacheDir, "buildpack_1", "layer2"),
+				filepath.Join(cacheDir, "buildpack2", "layer3"),
+				filepath.Join(cacheDir, "buildpack3", "layer4"),
+			)
+			mkfile(t, "file1", filepath.Join(cacheDir, "buildpack_1", "layer1", "file1"))
+			mkfile(t, "file2", filepath.Join(cacheDir, "buildpack_1", "layer2", "file2"))
+			mkfile(t, "file3", filepath.Join(cacheDir, "buildpack2", "layer3", "file3"))
+			mkfile(t, "file4", filepath.Join(cacheDir, "buildpack3", "layer4", "file4"))
+			mkfile(t, `key = "val"`, filepath.Join(cacheDir, "buildpack_1", "layer1.toml"))
+		})
+
+		it("should store a layer for each cache dir of each buildpack", func() {
+			if err := cacher.Cache(cacheDir, volumeCache); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			metadata, err := volumeCache.RetrieveMetadata()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			assertEq(t, len(metadata.Buildpacks), 2)
+			assertEq(t, metadata.Buildpacks[0].ID, "buildpack/1")
+			assertEq(t, len(metadata.Buildpacks[0].Layers), 2)
+			assertEq(t, metadata.Buildpacks[1].ID, "buildpack2")
+			assertEq(t, len(metadata.Buildpacks[1].Layers), 1)
+
+			layer1 := metadata.Buildpacks[0].Layers["layer1"]
+			assertTarFileContents(t,
+				filepath.Join(volumeDir, strings.TrimPrefix(layer1.SHA, "sha256:")+".tar"),
+				"buildpack_1/layer1/file1", "file1")
+			if s := cmp.Diff(layer1.Data, map[string]interface{}{"key": "val"}); s != "" {
+				t.Fatalf("Unexpected metadata:\n%s\n", s)
+			}
+			assertEq(t, metadata.Buildpacks[0].Layers["layer2"].Data, nil)
+			if !strings.Contains(stdout.String(), "caching layer 'buildpack/1/layer1' with SHA "+layer1.SHA+"\n") {
+				t.Fatalf("Unexpected output: %s\n", stdout)
+			}
+		})
+
+		it("should remove layers that are no longer cached", func() {
+			if err := cacher.Cache(cacheDir, volumeCache); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if err := os.RemoveAll(filepath.Join(cacheDir, "buildpack2")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			mkfile(t, "new-file1", filepath.Join(cacheDir, "buildpack_1", "layer1", "file1"))
+			if err := cacher.Cache(cacheDir, volumeCache); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+
+			metadata, err := volumeCache.RetrieveMetadata()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			assertEq(t, len(metadata.Buildpacks), 1)
+			tars, err := filepath.Glob(filepath.Join(volumeDir, "*.tar"))
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			assertEq(t, len(tars), 2)
+			assertTarFileContents(t,
+				filepath.Join(volumeDir, strings.TrimPrefix(metadata.Buildpacks[0].Layers["layer1"].SHA, "sha256:")+".tar"),
+				"buildpack_1/layer1/file1", "new-file1")
+		})
+	})
+}
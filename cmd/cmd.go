@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -21,9 +22,13 @@ const (
 	DefaultUseDaemon      = false
 	DefaultUseCredHelpers = false
 
-	EnvRunImage = "PACK_RUN_IMAGE"
-	EnvUID      = "PACK_USER_ID"
-	EnvGID      = "PACK_GROUP_ID"
+	EnvRunImage   = "PACK_RUN_IMAGE"
+	EnvBuildImage = "PACK_BUILD_IMAGE"
+	EnvUID        = "PACK_USER_ID"
+	EnvGID        = "PACK_GROUP_ID"
+	EnvStackID    = "PACK_STACK_ID"
+
+	EnvSourceDateEpoch = "SOURCE_DATE_EPOCH"
 )
 
 func FlagLaunchDir(dir *string) {
@@ -50,6 +55,14 @@ func FlagCacheDir(dir *string) {
 	flag.StringVar(dir, "cache", DefaultCacheDir, "path to cache directory")
 }
 
+func FlagCacheVolumeDir(dir *string) {
+	flag.StringVar(dir, "volume", "", "path to cache volume directory")
+}
+
+func FlagCacheImage(image *string) {
+	flag.StringVar(image, "cache-image", "", "reference to cache image")
+}
+
 func FlagBuildpacksDir(dir *string) {
 	flag.StringVar(dir, "buildpacks", DefaultBuildpacksDir, "path to buildpacks directory")
 }
@@ -74,6 +87,14 @@ func FlagRunImage(image *string) {
 	flag.StringVar(image, "image", os.Getenv(EnvRunImage), "reference to run image")
 }
 
+func FlagBuildImage(image *string) {
+	flag.StringVar(image, "build-image", os.Getenv(EnvBuildImage), "reference to build image")
+}
+
+func FlagOldRunImage(image *string) {
+	flag.StringVar(image, "old-image", "", "reference to run image that the image was built on")
+}
+
 func FlagMetadataPath(metadata *string) {
 	flag.StringVar(metadata, "metadata", "", "path to json containing image metadata for previous image")
 }
@@ -82,6 +103,44 @@ func FlagUseDaemon(use *bool) {
 	flag.BoolVar(use, "daemon", DefaultUseDaemon, "export to docker daemon")
 }
 
+func FlagLayoutDir(dir *string) {
+	flag.StringVar(dir, "layout", "", "path to OCI image layout directory to read and write images in")
+}
+
+func FlagTarballPath(path *string) {
+	flag.StringVar(path, "tarball", "", "path to write image to as a docker-archive tarball")
+}
+
+func FlagReportPath(path *string) {
+	flag.StringVar(path, "report", "", "path to write export report to (.json for JSON, otherwise TOML)")
+}
+
+func FlagDetectReportPath(path *string) {
+	flag.StringVar(path, "detect-report", "", "path to write detection report to (.json for JSON, otherwise TOML)")
+}
+
+func FlagPlanMerge(strategy *string) {
+	flag.StringVar(strategy, "plan-merge", "replace", "how to merge plan entries with the same name (replace, deep or deep-strict)")
+}
+
+func FlagValidate(validate *bool) {
+	flag.BoolVar(validate, "validate", false, "check the order file and buildpacks without running detection")
+}
+
+func FlagStackID(id *string) {
+	flag.StringVar(id, "stack", os.Getenv(EnvStackID), "ID of the stack, to exclude buildpacks that do not support it")
+}
+
+func FlagPlatformEnv(use *bool, allow, deny *string) {
+	flag.BoolVar(use, "platform-env", false, "provide variables in the platform env directory to buildpacks")
+	flag.StringVar(allow, "platform-env-allow", "", "comma-separated variables (or patterns) to provide from the platform env directory")
+	flag.StringVar(deny, "platform-env-deny", "", "comma-separated variables (or patterns) never to provide from the platform env directory")
+}
+
+func FlagDetectTimeout(timeout *time.Duration) {
+	flag.DurationVar(timeout, "detect-timeout", 0, "maximum time for each buildpack's detection (0 for no limit)")
+}
+
 func FlagUseCredHelpers(use *bool) {
 	flag.BoolVar(use, "helpers", DefaultUseCredHelpers, "use credential helpers")
 }
@@ -103,6 +162,8 @@ const (
 	CodeFailedBuild
 	CodeFailedLaunch
 	CodeFailedUpdate
+	CodeFailedRebase
+	CodeFailedValidate
 )
 
 type ErrorFail struct {
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"flag"
 	"io/ioutil"
 	"log"
 	"os"
@@ -14,35 +13,23 @@ import (
 	"github.com/buildpack/lifecycle/img"
 )
 
-var (
-	repoName     string
-	launchDir    string
-	groupPath    string
-	useDaemon    bool
-	useHelpers   bool
-	metadataPath string
-)
-
-func init() {
+func analyzerFlags() {
 	cmd.FlagLaunchDir(&launchDir)
 	cmd.FlagGroupPath(&groupPath)
 	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagLayoutDir(&layoutDir)
 	cmd.FlagUseCredHelpers(&useHelpers)
 	cmd.FlagMetadataPath(&metadataPath)
 }
 
-func main() {
-	flag.Parse()
-	repoName = flag.Arg(0)
-	if flag.NArg() > 1 || repoName == "" {
-		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments"))
+func analyzer(args []string) error {
+	if len(args) != 1 || args[0] == "" || (useDaemon && layoutDir != "") {
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments")
 	}
-	cmd.Exit(analyzer())
-}
+	repoName = args[0]
 
-func analyzer() error {
 	if useHelpers {
-		if err := img.SetupCredHelpers(repoName); err != nil {
+		if err := img.SetupCredHelpers(trimStorePrefixes(repoName)...); err != nil {
 			return cmd.FailErr(err, "setup credential helpers")
 		}
 	}
@@ -51,7 +38,10 @@ func analyzer() error {
 	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
 		return cmd.FailErr(err, "read group")
 	}
+	return analyze(&group)
+}
 
+func analyze(group *lifecycle.BuildpackGroup) error {
 	analyzer := &lifecycle.Analyzer{
 		Buildpacks: group.Buildpacks,
 		Out:        os.Stdout,
@@ -67,13 +57,9 @@ func analyzer() error {
 		metadata = string(bMetadata)
 	} else {
 		var err error
-		newRepoStore := img.NewRegistry
-		if useDaemon {
-			newRepoStore = img.NewDaemon
-		}
-		metadata, err = analyzer.GetMetadata(newRepoStore, repoName)
+		metadata, err = analyzer.GetMetadata(newRefStore, repoName)
 		if err != nil {
-			return cmd.FailErr(err, "access image metadata from image", metadataPath)
+			return cmd.FailErr(err, "access image metadata from image", repoName)
 		}
 	}
 
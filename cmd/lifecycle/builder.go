@@ -1,7 +1,6 @@
 package main
 
 import (
-	"flag"
 	"os"
 	"path/filepath"
 
@@ -11,17 +10,7 @@ import (
 	"github.com/buildpack/lifecycle/cmd"
 )
 
-var (
-	buildpacksDir string
-	groupPath     string
-	planPath      string
-	launchDir     string
-	appDir        string
-	cacheDir      string
-	platformDir   string
-)
-
-func init() {
+func builderFlags() {
 	cmd.FlagBuildpacksDir(&buildpacksDir)
 	cmd.FlagGroupPath(&groupPath)
 	cmd.FlagPlanPath(&planPath)
@@ -29,17 +18,15 @@ func init() {
 	cmd.FlagAppDir(&appDir)
 	cmd.FlagCacheDir(&cacheDir)
 	cmd.FlagPlatformDir(&platformDir)
+	cmd.FlagPlanMerge(&planMerge)
+	cmd.FlagPlatformEnv(&usePlatformEnv, &platformEnvAllow, &platformEnvDeny)
 }
 
-func main() {
-	flag.Parse()
-	if flag.NArg() != 0 {
-		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments"))
+func builder(args []string) error {
+	if len(args) != 0 {
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments")
 	}
-	cmd.Exit(build())
-}
 
-func build() error {
 	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
 	if err != nil {
 		return cmd.FailErr(err, "read buildpack directory")
@@ -53,13 +40,15 @@ func build() error {
 	if _, err := toml.DecodeFile(planPath, &plan); err != nil {
 		return cmd.FailErr(err, "parse build plan")
 	}
+	return build(group, plan)
+}
 
-	env := &lifecycle.Env{
-		Getenv:  os.Getenv,
-		Setenv:  os.Setenv,
-		Environ: os.Environ,
-		Map:     lifecycle.POSIXBuildEnv,
+func build(group *lifecycle.BuildpackGroup, plan lifecycle.Plan) error {
+	merge, err := lifecycle.ParsePlanMerge(planMerge)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeInvalidArgs, "parse plan merge strategy")
 	}
+	env := lifecycle.NewMemoryEnv(os.Environ(), lifecycle.POSIXBuildEnv)
 	builder := &lifecycle.Builder{
 		PlatformDir: platformDir,
 		CacheDir:    cacheDir,
@@ -68,6 +57,8 @@ func build() error {
 		Env:         env,
 		Buildpacks:  group.Buildpacks,
 		Plan:        plan,
+		Merge:       merge,
+		PlatformEnv: platformEnv(),
 		Out:         os.Stdout,
 		Err:         os.Stderr,
 	}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+func cacherFlags() {
+	cmd.FlagCacheDir(&cacheDir)
+	cmd.FlagCacheVolumeDir(&cacheVolumeDir)
+	cmd.FlagCacheImage(&cacheImageRef)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagUseCredHelpers(&useHelpers)
+	cmd.FlagUID(&uid)
+	cmd.FlagGID(&gid)
+}
+
+func cacher(args []string) error {
+	if len(args) != 0 || (cacheVolumeDir == "") == (cacheImageRef == "") {
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments")
+	}
+
+	var group lifecycle.BuildpackGroup
+	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
+		return cmd.FailErr(err, "read group")
+	}
+
+	cacheStore, err := newCacheStore()
+	if err != nil {
+		return err
+	}
+
+	artifactsDir, err := ioutil.TempDir("", "lifecycle.cacher.layer")
+	if err != nil {
+		return cmd.FailErr(err, "create temp directory")
+	}
+	defer os.RemoveAll(artifactsDir)
+
+	cacher := &lifecycle.Cacher{
+		Buildpacks:   group.Buildpacks,
+		ArtifactsDir: artifactsDir,
+		Out:          os.Stdout,
+		UID:          uid,
+		GID:          gid,
+	}
+	if err := cacher.Cache(cacheDir, cacheStore); err != nil {
+		return cmd.FailErr(err, "cache")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+func creatorFlags() {
+	cmd.FlagRunImage(&runImageRef)
+	cmd.FlagBuildImage(&buildImageRef)
+	cmd.FlagBuildpacksDir(&buildpacksDir)
+	cmd.FlagOrderPath(&orderPath)
+	cmd.FlagDetectTimeout(&detectTimeout)
+	cmd.FlagDetectReportPath(&detectReport)
+	cmd.FlagPlanMerge(&planMerge)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagPlatformEnv(&usePlatformEnv, &platformEnvAllow, &platformEnvDeny)
+	cmd.FlagLaunchDir(&launchDir)
+	cmd.FlagAppDir(&appDir)
+	cmd.FlagCacheDir(&cacheDir)
+	cmd.FlagPlatformDir(&platformDir)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagLayoutDir(&layoutDir)
+	cmd.FlagTarballPath(&tarballPath)
+	cmd.FlagReportPath(&reportPath)
+	cmd.FlagUseCredHelpers(&useHelpers)
+	cmd.FlagUID(&uid)
+	cmd.FlagGID(&gid)
+}
+
+func creator(args []string) error {
+	if err := parseExportArgs(args); err != nil {
+		return err
+	}
+	launchDirSrc, appDirSrc = launchDir, appDir
+
+	if useHelpers {
+		if err := setupExportCredHelpers(); err != nil {
+			return err
+		}
+	}
+
+	if stackID == "" && buildImageRef != "" {
+		id, err := imageStackID(buildImageRef)
+		if err != nil {
+			return err
+		}
+		stackID = id
+	}
+	if stackID == "" {
+		id, err := imageStackID(runImageRef)
+		if err != nil {
+			return err
+		}
+		stackID = id
+	}
+
+	group, info, err := detect()
+	if err != nil {
+		return err
+	}
+	var plan lifecycle.Plan
+	if _, err := toml.Decode(string(info), &plan); err != nil {
+		return cmd.FailErr(err, "parse build plan")
+	}
+	if tarballPath != "" && !hasStorePrefix(repoName) {
+		log.Printf("WARNING: skipping analyze, no previous image is read from a tarball")
+	} else if err := analyze(group); err != nil {
+		return err
+	}
+	if err := build(group, plan); err != nil {
+		return err
+	}
+	return export(group)
+}
+
+// imageStackID returns the stack ID from the label on the image.
+func imageStackID(ref string) (string, error) {
+	store, err := newStore()(ref)
+	if err != nil {
+		return "", cmd.FailErr(err, "access", ref)
+	}
+	image, err := store.Image()
+	if err != nil {
+		return "", cmd.FailErr(err, "get image for", ref)
+	}
+	config, err := image.ConfigFile()
+	if err != nil {
+		return "", cmd.FailErr(err, "get config for", ref)
+	}
+	return config.Config.Labels[lifecycle.StackIDLabel], nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+func detectorFlags() {
+	cmd.FlagBuildpacksDir(&buildpacksDir)
+	cmd.FlagAppDir(&appDir)
+	cmd.FlagPlatformDir(&platformDir)
+	cmd.FlagOrderPath(&orderPath)
+	cmd.FlagDetectTimeout(&detectTimeout)
+	cmd.FlagDetectReportPath(&detectReport)
+	cmd.FlagPlanMerge(&planMerge)
+	cmd.FlagStackID(&stackID)
+	cmd.FlagPlatformEnv(&usePlatformEnv, &platformEnvAllow, &platformEnvDeny)
+	cmd.FlagValidate(&validate)
+
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagPlanPath(&planPath)
+}
+
+func detector(args []string) error {
+	if len(args) != 0 {
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments")
+	}
+
+	if validate {
+		return validateOrder()
+	}
+
+	group, info, err := detect()
+	if err != nil {
+		return err
+	}
+
+	if err := group.Write(groupPath); err != nil {
+		return cmd.FailErr(err, "write buildpack group")
+	}
+
+	if err := ioutil.WriteFile(planPath, info, 0666); err != nil {
+		return cmd.FailErr(err, "write detect info")
+	}
+
+	return nil
+}
+
+func detect() (*lifecycle.BuildpackGroup, []byte, error) {
+	merge, err := lifecycle.ParsePlanMerge(planMerge)
+	if err != nil {
+		return nil, nil, cmd.FailErrCode(err, cmd.CodeInvalidArgs, "parse plan merge strategy")
+	}
+	errLog := log.New(os.Stderr, "", log.LstdFlags)
+	outLog := log.New(os.Stdout, "", log.LstdFlags)
+
+	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
+	if err != nil {
+		return nil, nil, cmd.FailErr(err, "read buildpack directory")
+	}
+	order, err := buildpacks.ReadOrder(orderPath)
+	if err != nil {
+		return nil, nil, cmd.FailErr(err, "read buildpack order file")
+	}
+	order, excluded := order.ForStack(stackID)
+	for _, name := range excluded {
+		outLog.Printf("%s: incompatible with stack '%s'", name, stackID)
+	}
+
+	config := &lifecycle.DetectConfig{
+		AppDir:      appDir,
+		PlatformDir: platformDir,
+		StackID:     stackID,
+		PlatformEnv: platformEnv(),
+		Timeout:     detectTimeout,
+		Merge:       merge,
+		Out:         outLog,
+		Err:         errLog,
+	}
+	if detectReport != "" {
+		config.Report = &lifecycle.DetectReport{}
+	}
+	info, group := order.Detect(config)
+	if config.Report != nil {
+		if err := writeReport(detectReport, config.Report); err != nil {
+			return nil, nil, cmd.FailErr(err, "write detection report")
+		}
+	}
+	if group == nil {
+		return nil, nil, cmd.FailCode(cmd.CodeFailedDetect, "detect")
+	}
+	return group, info, nil
+}
+
+func validateOrder() error {
+	problems, err := lifecycle.LintOrder(buildpacksDir, orderPath)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack order file")
+	}
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, "Error:", p)
+	}
+	if len(problems) > 0 {
+		return cmd.FailCode(cmd.CodeFailedValidate, "validate buildpack order")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+)
+
+func developerFlags() {
+	cmd.FlagBuildpacksDir(&buildpacksDir)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagPlanPath(&planPath)
+	cmd.FlagLaunchDir(&launchDir)
+	cmd.FlagAppDir(&appDir)
+	cmd.FlagCacheDir(&cacheDir)
+	cmd.FlagPlatformDir(&platformDir)
+}
+
+func developer(args []string) error {
+	defaultProcessType := "web"
+	if v := os.Getenv("PACK_PROCESS_TYPE"); v != "" {
+		defaultProcessType = v
+	}
+
+	buildpacks, err := lifecycle.NewBuildpackMap(buildpacksDir)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack directory")
+	}
+	group, err := buildpacks.ReadGroup(groupPath)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack group")
+	}
+
+	var plan lifecycle.Plan
+	if _, err := toml.DecodeFile(planPath, &plan); err != nil {
+		return cmd.FailErr(err, "parse build plan")
+	}
+
+	env := lifecycle.NewMemoryEnv(os.Environ(), lifecycle.POSIXBuildEnv)
+	developer := &lifecycle.Developer{
+		PlatformDir:        platformDir,
+		CacheDir:           cacheDir,
+		LaunchDir:          launchDir,
+		AppDir:             appDir,
+		DefaultProcessType: defaultProcessType,
+		Env:                env,
+		Buildpacks:         group.Buildpacks,
+		Plan:               plan,
+		Out:                os.Stdout,
+		Err:                os.Stderr,
+		Exec:               syscall.Exec,
+	}
+
+	if err := developer.Develop(args); err != nil {
+		return cmd.FailErrCode(err, cmd.CodeFailedBuild)
+	}
+	return nil
+}
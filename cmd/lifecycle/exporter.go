@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+	"github.com/buildpack/lifecycle/img"
+)
+
+func exporterFlags() {
+	cmd.FlagRunImage(&runImageRef)
+	cmd.FlagLaunchDir(&launchDir)
+	cmd.FlagLaunchDirSrc(&launchDirSrc)
+	cmd.FlagAppDir(&appDir)
+	cmd.FlagAppDirSrc(&appDirSrc)
+	cmd.FlagDryRunDir(&dryRun)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagLayoutDir(&layoutDir)
+	cmd.FlagTarballPath(&tarballPath)
+	cmd.FlagReportPath(&reportPath)
+	cmd.FlagUseCredHelpers(&useHelpers)
+	cmd.FlagUID(&uid)
+	cmd.FlagGID(&gid)
+}
+
+func exporter(args []string) error {
+	if err := parseExportArgs(args); err != nil {
+		return err
+	}
+
+	var group lifecycle.BuildpackGroup
+	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
+		return cmd.FailErr(err, "read group")
+	}
+
+	if useHelpers && dryRun == "" {
+		if err := setupExportCredHelpers(); err != nil {
+			return err
+		}
+	}
+	return export(&group)
+}
+
+// parseExportArgs sets the app image references for the exporter and creator.
+func parseExportArgs(args []string) error {
+	if !validRepoNames(args) || runImageRef == "" || (useDaemon && layoutDir != "") {
+		args := map[string]interface{}{"narg": len(args), "runImage": runImageRef, "launchDir": launchDir}
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments", fmt.Sprintf("%+v", args))
+	}
+	repoNames, repoName = args, args[0]
+	return nil
+}
+
+func setupExportCredHelpers() error {
+	refs := append(trimStorePrefixes(repoNames...), runImageRef)
+	if buildImageRef != "" {
+		refs = append(refs, buildImageRef)
+	}
+	if err := img.SetupCredHelpers(refs...); err != nil {
+		return cmd.FailErr(err, "setup credential helpers")
+	}
+	return nil
+}
+
+func export(group *lifecycle.BuildpackGroup) error {
+	var err error
+	exporter := &lifecycle.Exporter{
+		Buildpacks: group.Buildpacks,
+		Out:        os.Stdout,
+		Err:        os.Stderr,
+		UID:        uid,
+		GID:        gid,
+	}
+
+	if v := os.Getenv(cmd.EnvSourceDateEpoch); v != "" {
+		epoch, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return cmd.FailErrCode(err, cmd.CodeInvalidEnv, "parse", cmd.EnvSourceDateEpoch)
+		}
+		sourceDate := time.Unix(epoch, 0).UTC()
+		exporter.SourceDate = &sourceDate
+	}
+
+	if dryRun != "" {
+		exporter.ArtifactsDir = dryRun
+		if err := os.MkdirAll(exporter.ArtifactsDir, 0777); err != nil {
+			return cmd.FailErr(err, "create temp directory")
+		}
+	} else {
+		exporter.ArtifactsDir, err = ioutil.TempDir("", "lifecycle.exporter.layer")
+		if err != nil {
+			return cmd.FailErr(err, "create temp directory")
+		}
+		defer os.RemoveAll(exporter.ArtifactsDir)
+	}
+
+	err = exporter.PrepareExport(
+		launchDirSrc,
+		launchDir,
+		appDirSrc,
+		appDir,
+	)
+	if err != nil {
+		return cmd.FailErr(err, "prepare export")
+	}
+
+	if dryRun != "" {
+		return nil
+	}
+
+	var repoStores []img.Store
+	for _, name := range repoNames {
+		repoStore, err := newRefStore(name)
+		if err != nil {
+			return cmd.FailErr(err, "access", name)
+		}
+		repoStores = append(repoStores, repoStore)
+	}
+
+	runImageStore, err := newStore()(runImageRef)
+	if err != nil {
+		return cmd.FailErr(err, "access", runImageRef)
+	}
+	runImage, err := runImageStore.Image()
+	if err != nil {
+		return cmd.FailErr(err, "get image for", runImageRef)
+	}
+
+	// The first reference provides the previous image for layer reuse
+	origImage, err := repoStores[0].Image()
+	if err != nil {
+		origImage = nil
+	} else if _, err := origImage.RawManifest(); err != nil {
+		// Assume error is due to non-existent image
+		// This is necessary for registries
+		origImage = nil
+	}
+
+	newImage, report, err := exporter.ExportImage(
+		launchDir,
+		appDir,
+		runImage,
+		origImage,
+	)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeFailedBuild)
+	}
+
+	if err := img.WriteAll(newImage, repoStores...); err != nil {
+		return cmd.FailErrCode(err, cmd.CodeFailedUpdate, "write")
+	}
+
+	if reportPath != "" {
+		report.Image.Tags = trimStorePrefixes(repoNames...)
+		if err := writeReport(reportPath, report); err != nil {
+			return cmd.FailErr(err, "write export report")
+		}
+	}
+
+	return nil
+}
+
+func writeReport(path string, report interface{}) error {
+	if filepath.Ext(path) == ".json" {
+		return lifecycle.WriteJSON(path, report)
+	}
+	return lifecycle.WriteTOML(path, report)
+}
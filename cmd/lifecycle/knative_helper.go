@@ -7,29 +7,22 @@ import (
 	"github.com/buildpack/lifecycle/cmd"
 )
 
-var (
-	launchDir string
-	uid       int
-	gid       int
-)
-
 const knativeBuildHomeDir = "/builder/home"
 const knativeWorkspaceDir = "/workspace"
 const knativeCacheDir = "/cache"
 
-func init() {
+func knativeHelperFlags() {
 	flag.StringVar(&launchDir, "launch", knativeWorkspaceDir, "path to launch directory")
 	cmd.FlagUID(&uid)
 	cmd.FlagGID(&gid)
 }
 
-func main() {
-	flag.Parse()
+func knativeHelper(args []string) error {
 	if launchDir == "" {
-		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "empty launch dir"))
+		return cmd.FailCode(cmd.CodeInvalidArgs, "empty launch dir")
 	}
 	if err := lifecycle.SetupKnativeLaunchDir(launchDir); err != nil {
-		cmd.Exit(cmd.FailCode(cmd.CodeFailed, "moving app dir"))
+		return cmd.FailCode(cmd.CodeFailed, "moving app dir")
 	}
-	cmd.Exit(lifecycle.ChownDirs(launchDir, knativeBuildHomeDir, knativeCacheDir, uid, gid))
+	return lifecycle.ChownDirs(launchDir, knativeBuildHomeDir, knativeCacheDir, uid, gid)
 }
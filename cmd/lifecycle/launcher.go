@@ -3,7 +3,6 @@ package main
 import (
 	"os"
 	"path/filepath"
-	"strings"
 	"syscall"
 
 	"github.com/BurntSushi/toml"
@@ -12,16 +11,7 @@ import (
 	"github.com/buildpack/lifecycle/cmd"
 )
 
-var (
-	launchDir string
-	appDir    string
-)
-
-func main() {
-	cmd.Exit(launch())
-}
-
-func launch() error {
+func launcher(args []string) error {
 	defaultProcessType := "web"
 	if v := os.Getenv("PACK_PROCESS_TYPE"); v != "" {
 		defaultProcessType = v
@@ -54,7 +44,7 @@ func launch() error {
 		Exec:               syscall.Exec,
 	}
 
-	if err := launcher.Launch(os.Args[0], strings.Join(os.Args[1:], " ")); err != nil {
+	if err := launcher.Launch(os.Args[0], args); err != nil {
 		return cmd.FailErrCode(err, cmd.CodeFailedLaunch, "launch")
 	}
 	return nil
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+	"github.com/buildpack/lifecycle/img"
+)
+
+var (
+	repoName         string
+	repoNames        []string
+	runImageRef      string
+	oldRunImageRef   string
+	buildImageRef    string
+	buildpacksDir    string
+	orderPath        string
+	groupPath        string
+	planPath         string
+	launchDir        string
+	launchDirSrc     string
+	appDir           string
+	appDirSrc        string
+	cacheDir         string
+	cacheVolumeDir   string
+	cacheImageRef    string
+	platformDir      string
+	metadataPath     string
+	dryRun           string
+	layoutDir        string
+	tarballPath      string
+	reportPath       string
+	detectReport     string
+	planMerge        string
+	stackID          string
+	platformEnvAllow string
+	platformEnvDeny  string
+	usePlatformEnv   bool
+	detectTimeout    time.Duration
+	validate         bool
+	useDaemon        bool
+	useHelpers       bool
+	uid              int
+	gid              int
+)
+
+type phase struct {
+	flags func()
+	run   func(args []string) error
+}
+
+var phases = map[string]phase{
+	"detector":       {detectorFlags, detector},
+	"analyzer":       {analyzerFlags, analyzer},
+	"builder":        {builderFlags, builder},
+	"exporter":       {exporterFlags, exporter},
+	"launcher":       {nil, launcher},
+	"knative-helper": {knativeHelperFlags, knativeHelper},
+	"creator":        {creatorFlags, creator},
+	"rebaser":        {rebaserFlags, rebaser},
+	"developer":      {developerFlags, developer},
+	"retriever":      {retrieverFlags, retriever},
+	"cacher":         {cacherFlags, cacher},
+}
+
+// main dispatches on the name the binary was invoked as (e.g., via a
+// /lifecycle/detector symlink) or, failing that, on the first argument.
+func main() {
+	name, args := filepath.Base(os.Args[0]), os.Args[1:]
+	if _, ok := phases[name]; !ok && len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	p, ok := phases[name]
+	if !ok {
+		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "find phase", name, "(expected one of: "+strings.Join(phaseNames(), ", ")+")"))
+	}
+	if p.flags != nil {
+		flag.CommandLine = flag.NewFlagSet(name, flag.ExitOnError)
+		p.flags()
+		flag.CommandLine.Parse(args)
+		args = flag.Args()
+	}
+	cmd.Exit(p.run(args))
+}
+
+func phaseNames() []string {
+	var names []string
+	for name := range phases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newStore() func(string) (img.Store, error) {
+	if layoutDir != "" {
+		return func(ref string) (img.Store, error) {
+			return img.NewLayout(layoutDir, ref)
+		}
+	}
+	if useDaemon {
+		return img.NewDaemon
+	}
+	return img.NewRegistry
+}
+
+var storePrefixes = map[string]func(string) (img.Store, error){
+	"registry://": img.NewRegistry,
+	"daemon://":   img.NewDaemon,
+	"layout://": func(ref string) (img.Store, error) {
+		if layoutDir == "" {
+			return nil, errors.New("layout directory not provided")
+		}
+		return img.NewLayout(layoutDir, ref)
+	},
+}
+
+// newRefStore returns a Store for ref. A registry://, daemon:// or layout://
+// prefix selects the store, otherwise the store chosen by flags is used.
+func newRefStore(ref string) (img.Store, error) {
+	for prefix, newPrefixStore := range storePrefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return newPrefixStore(strings.TrimPrefix(ref, prefix))
+		}
+	}
+	if tarballPath != "" {
+		return img.NewTarball(tarballPath, ref)
+	}
+	return newStore()(ref)
+}
+
+// validRepoNames checks the app image references passed to a phase. Only
+// one reference may be written to a tarball, since it holds a single image.
+func validRepoNames(refs []string) bool {
+	unprefixed := 0
+	for _, ref := range refs {
+		if ref == "" {
+			return false
+		}
+		if !hasStorePrefix(ref) {
+			unprefixed++
+		}
+	}
+	return len(refs) > 0 && (tarballPath == "" || unprefixed <= 1)
+}
+
+func hasStorePrefix(ref string) bool {
+	for prefix := range storePrefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimStorePrefixes(refs ...string) []string {
+	var trimmed []string
+	for _, ref := range refs {
+		for prefix := range storePrefixes {
+			ref = strings.TrimPrefix(ref, prefix)
+		}
+		trimmed = append(trimmed, ref)
+	}
+	return trimmed
+}
+
+func platformEnv() *lifecycle.PlatformEnv {
+	if !usePlatformEnv {
+		return nil
+	}
+	return &lifecycle.PlatformEnv{
+		Allow: splitList(platformEnvAllow),
+		Deny:  splitList(platformEnvDeny),
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cmd"
+	"github.com/buildpack/lifecycle/img"
+)
+
+func rebaserFlags() {
+	cmd.FlagRunImage(&runImageRef)
+	cmd.FlagOldRunImage(&oldRunImageRef)
+	cmd.FlagUseDaemon(&useDaemon)
+	cmd.FlagLayoutDir(&layoutDir)
+	cmd.FlagUseCredHelpers(&useHelpers)
+}
+
+func rebaser(args []string) error {
+	if len(args) != 1 || args[0] == "" || runImageRef == "" || (useDaemon && layoutDir != "") {
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments")
+	}
+	repoName = args[0]
+
+	if useHelpers {
+		refs := []string{repoName, runImageRef}
+		if oldRunImageRef != "" {
+			refs = append(refs, oldRunImageRef)
+		}
+		if err := img.SetupCredHelpers(refs...); err != nil {
+			return cmd.FailErr(err, "setup credential helpers")
+		}
+	}
+
+	newImageStore := newStore()
+	repoStore, err := newImageStore(repoName)
+	if err != nil {
+		return cmd.FailErr(err, "access", repoName)
+	}
+	origImage, err := repoStore.Image()
+	if err != nil {
+		return cmd.FailErr(err, "get image for", repoName)
+	}
+
+	runImageStore, err := newImageStore(runImageRef)
+	if err != nil {
+		return cmd.FailErr(err, "access", runImageRef)
+	}
+	runImage, err := runImageStore.Image()
+	if err != nil {
+		return cmd.FailErr(err, "get image for", runImageRef)
+	}
+
+	rebaser := &lifecycle.Rebaser{
+		Out: os.Stdout,
+		Err: os.Stderr,
+	}
+	if oldRunImageRef != "" {
+		oldRunImageStore, err := newImageStore(oldRunImageRef)
+		if err != nil {
+			return cmd.FailErr(err, "access", oldRunImageRef)
+		}
+		if rebaser.OldBaseImage, err = oldRunImageStore.Image(); err != nil {
+			return cmd.FailErr(err, "get image for", oldRunImageRef)
+		}
+	}
+	newImage, err := rebaser.Rebase(origImage, runImage)
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeFailedRebase)
+	}
+
+	if err := repoStore.Write(newImage); err != nil {
+		return cmd.FailErrCode(err, cmd.CodeFailedUpdate, "write")
+	}
+	return nil
+}
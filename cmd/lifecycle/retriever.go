@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cache"
+	"github.com/buildpack/lifecycle/cmd"
+	"github.com/buildpack/lifecycle/img"
+)
+
+func retrieverFlags() {
+	cmd.FlagCacheDir(&cacheDir)
+	cmd.FlagCacheVolumeDir(&cacheVolumeDir)
+	cmd.FlagCacheImage(&cacheImageRef)
+	cmd.FlagGroupPath(&groupPath)
+	cmd.FlagUseCredHelpers(&useHelpers)
+	cmd.FlagUID(&uid)
+	cmd.FlagGID(&gid)
+}
+
+func retriever(args []string) error {
+	if len(args) != 0 || (cacheVolumeDir == "") == (cacheImageRef == "") {
+		return cmd.FailCode(cmd.CodeInvalidArgs, "parse arguments")
+	}
+
+	var group lifecycle.BuildpackGroup
+	if _, err := toml.DecodeFile(groupPath, &group); err != nil {
+		return cmd.FailErr(err, "read group")
+	}
+
+	cacheStore, err := newCacheStore()
+	if err != nil {
+		return err
+	}
+
+	retriever := &lifecycle.Retriever{
+		Buildpacks: group.Buildpacks,
+		Out:        os.Stdout,
+		Err:        os.Stderr,
+		UID:        uid,
+		GID:        gid,
+	}
+	if err := retriever.Retrieve(cacheDir, cacheStore); err != nil {
+		return cmd.FailErr(err, "retrieve cache")
+	}
+	return nil
+}
+
+func newCacheStore() (lifecycle.CacheStore, error) {
+	if cacheVolumeDir != "" {
+		cacheStore, err := cache.NewVolumeCache(cacheVolumeDir)
+		if err != nil {
+			return nil, cmd.FailErr(err, "access cache volume", cacheVolumeDir)
+		}
+		return cacheStore, nil
+	}
+	if useHelpers {
+		if err := img.SetupCredHelpers(cacheImageRef); err != nil {
+			return nil, cmd.FailErr(err, "setup credential helpers")
+		}
+	}
+	imageStore, err := img.NewRegistry(cacheImageRef)
+	if err != nil {
+		return nil, cmd.FailErr(err, "access", cacheImageRef)
+	}
+	return cache.NewImageCache(imageStore), nil
+}
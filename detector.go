@@ -2,15 +2,20 @@ package lifecycle
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -19,20 +24,71 @@ const (
 	CodeDetectPass = iota
 	CodeDetectError
 	CodeDetectFail = 100
+	// CodeDetectTimeout is outside the range of exit statuses, so that
+	// it cannot be confused with a bin/detect that exits with any status.
+	CodeDetectTimeout = -1
 )
 
 type Buildpack struct {
-	ID       string `toml:"id"`
-	Version  string `toml:"version"`
-	Optional bool   `toml:"optional,omitempty"`
-	Name     string `toml:"-"`
-	Dir      string `toml:"-"`
+	ID            string        `toml:"id"`
+	Version       string        `toml:"version"`
+	Optional      bool          `toml:"optional,omitempty"`
+	Name          string        `toml:"-"`
+	Dir           string        `toml:"-"`
+	DetectTimeout time.Duration `toml:"-"`
+	// Stacks lists the IDs of the stacks the buildpack supports.
+	// A buildpack without stacks, or with the stack "*", supports any stack.
+	Stacks []string `toml:"-"`
+	// Order makes this a meta-buildpack that stands for one of the groups
+	// in the order instead of running its own detection.
+	Order BuildpackOrder `toml:"-"`
 }
 
 type DetectConfig struct {
 	AppDir      string
 	PlatformDir string
-	Out, Err    *log.Logger
+	// PlatformEnv, if set, adds the platform env directory to the
+	// environment of each bin/detect.
+	PlatformEnv *PlatformEnv
+	// StackID excludes buildpacks that do not support the stack.
+	// An empty StackID excludes no buildpacks.
+	StackID string
+	// Timeout limits each bin/detect unless the buildpack sets its own.
+	// Zero means no limit.
+	Timeout time.Duration
+	// Context cancels detection when done. A nil Context never cancels.
+	Context context.Context
+	// Report, if set, records the outcome of every group tried.
+	Report *DetectReport
+	// Merge combines plan entries contributed by multiple buildpacks.
+	Merge    PlanMerge
+	Out, Err *log.Logger
+}
+
+type DetectReport struct {
+	Groups []GroupDetectReport `toml:"groups" json:"groups"`
+}
+
+type GroupDetectReport struct {
+	Buildpacks []BuildpackDetectReport `toml:"buildpacks" json:"buildpacks"`
+	Pass       bool                    `toml:"pass" json:"pass"`
+	Selected   bool                    `toml:"selected" json:"selected"`
+}
+
+type BuildpackDetectReport struct {
+	ID       string  `toml:"id" json:"id"`
+	Version  string  `toml:"version" json:"version"`
+	Optional bool    `toml:"optional" json:"optional"`
+	Code     int     `toml:"code" json:"code"`
+	Seconds  float64 `toml:"seconds" json:"seconds"`
+	Output   string  `toml:"output" json:"output"`
+	Plan     Plan    `toml:"plan" json:"plan"`
+	Provides Plan    `toml:"provides" json:"provides"`
+	Requires Plan    `toml:"requires" json:"requires"`
+
+	// Incompatible is set if the buildpack was excluded from detection
+	// because it does not support the stack.
+	Incompatible bool `toml:"incompatible" json:"incompatible"`
 }
 
 func (bp *Buildpack) EscapedID() string {
@@ -40,25 +96,42 @@ func (bp *Buildpack) EscapedID() string {
 }
 
 func (bp *Buildpack) Detect(c *DetectConfig, in io.Reader, out io.Writer) int {
+	return bp.detect(c, in, out).code
+}
+
+type detectResult struct {
+	code         int
+	incompatible bool
+	output       []byte
+	provides     Plan
+	requires     Plan
+}
+
+// detect runs bin/detect, returning its result code, combined output and
+// the entries it explicitly provides and requires.
+func (bp *Buildpack) detect(c *DetectConfig, in io.Reader, out io.Writer) detectResult {
+	if !bp.SupportsStack(c.StackID) {
+		return detectResult{code: CodeDetectFail, incompatible: true}
+	}
 	detectPath, err := filepath.Abs(filepath.Join(bp.Dir, "bin", "detect"))
 	if err != nil {
 		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return detectResult{code: CodeDetectError}
 	}
 	appDir, err := filepath.Abs(c.AppDir)
 	if err != nil {
 		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return detectResult{code: CodeDetectError}
 	}
 	platformDir, err := filepath.Abs(c.PlatformDir)
 	if err != nil {
 		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return detectResult{code: CodeDetectError}
 	}
 	planDir, err := ioutil.TempDir("", filepath.Base(bp.Dir)+".plan.")
 	if err != nil {
 		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return detectResult{code: CodeDetectError}
 	}
 	defer os.RemoveAll(planDir)
 	log := &bytes.Buffer{}
@@ -67,45 +140,130 @@ func (bp *Buildpack) Detect(c *DetectConfig, in io.Reader, out io.Writer) int {
 			c.Out.Printf("======== Output: %s ========\n%s", bp.Name, log)
 		}
 	}()
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout := bp.detectTimeout(c); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	cmd := exec.Command(detectPath, platformDir, planDir)
+	if c.PlatformEnv != nil {
+		env := NewMemoryEnv(os.Environ(), nil)
+		if err := c.PlatformEnv.AddTo(env, platformDir); err != nil {
+			c.Err.Print("Error: ", err)
+			return detectResult{code: CodeDetectError, output: log.Bytes()}
+		}
+		cmd.Env = env.List()
+	}
 	cmd.Dir = appDir
 	cmd.Stdin = in
 	cmd.Stdout = log
 	cmd.Stderr = log
-	if err := cmd.Run(); err != nil {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := runContext(ctx, cmd); err == context.DeadlineExceeded || err == context.Canceled {
+		c.Err.Printf("Error: %s detection stopped: %s", bp.Name, err)
+		return detectResult{code: CodeDetectTimeout, output: log.Bytes()}
+	} else if err != nil {
 		if err, ok := err.(*exec.ExitError); ok {
 			if status, ok := err.Sys().(syscall.WaitStatus); ok {
-				return status.ExitStatus()
+				return detectResult{code: status.ExitStatus(), output: log.Bytes()}
 			}
 		}
 		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return detectResult{code: CodeDetectError, output: log.Bytes()}
 	}
-	if err := parsePlan(out, planDir); err != nil {
+	provides, requires, err := parsePlan(out, planDir)
+	if err != nil {
 		c.Err.Print("Error: ", err)
-		return CodeDetectError
+		return detectResult{code: CodeDetectError, output: log.Bytes()}
+	}
+	return detectResult{
+		code:     CodeDetectPass,
+		output:   log.Bytes(),
+		provides: provides,
+		requires: requires,
 	}
-	return CodeDetectPass
 }
 
-func parsePlan(out io.Writer, planDir string) error {
-	files, err := ioutil.ReadDir(planDir)
-	if err != nil {
+func (bp *Buildpack) SupportsStack(id string) bool {
+	if id == "" || len(bp.Stacks) == 0 {
+		return true
+	}
+	for _, stack := range bp.Stacks {
+		if stack == id || stack == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (bp *Buildpack) detectTimeout(c *DetectConfig) time.Duration {
+	if bp.DetectTimeout > 0 {
+		return bp.DetectTimeout
+	}
+	return c.Timeout
+}
+
+// runContext runs cmd until it exits or ctx is done. When ctx is done
+// first, cmd's entire process group is killed so that processes it
+// started cannot hold its output open, and ctx.Err() is returned.
+func runContext(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
 		return err
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return ctx.Err()
+	}
+}
+
+// parsePlan writes the entries in planDir to out, and returns the entries
+// in its provides and requires subdirectories.
+func parsePlan(out io.Writer, planDir string) (provides, requires Plan, err error) {
+	m, err := readPlanDir(planDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if provides, err = readPlanDir(filepath.Join(planDir, "provides")); err != nil {
+		return nil, nil, err
+	}
+	if requires, err = readPlanDir(filepath.Join(planDir, "requires")); err != nil {
+		return nil, nil, err
+	}
+	return provides, requires, toml.NewEncoder(out).Encode(m)
+}
+
+func readPlanDir(dir string) (Plan, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 	m := Plan{}
 	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
-		path := filepath.Join(planDir, f.Name())
+		path := filepath.Join(dir, f.Name())
 		var entry map[string]interface{}
 		if _, err := toml.DecodeFile(path, &entry); err != nil {
-			return err
+			return nil, err
 		}
 		m[f.Name()] = entry
 	}
-	return toml.NewEncoder(out).Encode(m)
+	return m, nil
 }
 
 type BuildpackGroup struct {
@@ -116,33 +274,55 @@ func (bg *BuildpackGroup) Detect(c *DetectConfig) (plan []byte, group *Buildpack
 	group = &BuildpackGroup{}
 	detected := true
 	c.Out.Printf("Trying group of %d...", len(bg.Buildpacks))
-	plan, codes := bg.pDetect(c)
+	plan, results, reports := bg.pDetect(c)
+	var passed []detectResult
 	c.Out.Printf("======== Results ========")
-	for i, code := range codes {
+	for i, result := range results {
 		name := bg.Buildpacks[i].Name
 		optional := bg.Buildpacks[i].Optional
-		switch code {
+		switch code := result.code; code {
 		case CodeDetectPass:
 			c.Out.Printf("%s: pass", name)
 			group.Buildpacks = append(group.Buildpacks, bg.Buildpacks[i])
+			passed = append(passed, result)
 		case CodeDetectFail:
-			if optional {
+			if result.incompatible {
+				c.Out.Printf("%s: incompatible with stack '%s'", name, c.StackID)
+			} else if optional {
 				c.Out.Printf("%s: skip", name)
 			} else {
 				c.Out.Printf("%s: fail", name)
 			}
 			detected = detected && optional
+		case CodeDetectTimeout:
+			c.Out.Printf("%s: timeout", name)
+			detected = detected && optional
 		default:
 			c.Out.Printf("%s: error (%d)", name, code)
 			detected = detected && optional
 		}
 	}
 	detected = detected && len(group.Buildpacks) > 0
+	if detected {
+		var problems []string
+		plan, problems = resolvePlan(c.Err, plan, group.Buildpacks, passed)
+		for _, p := range problems {
+			c.Err.Print("Error: ", p)
+		}
+		detected = len(problems) == 0
+	}
+	if c.Report != nil {
+		c.Report.Groups = append(c.Report.Groups, GroupDetectReport{
+			Buildpacks: reports,
+			Pass:       detected,
+		})
+	}
 	return plan, group, detected
 }
 
-func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, codes []int) {
-	codes = make([]int, len(bg.Buildpacks))
+func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, results []detectResult, reports []BuildpackDetectReport) {
+	results = make([]detectResult, len(bg.Buildpacks))
+	reports = make([]BuildpackDetectReport, len(bg.Buildpacks))
 	wg := sync.WaitGroup{}
 	defer wg.Wait()
 	wg.Add(len(bg.Buildpacks))
@@ -152,24 +332,40 @@ func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, codes []int) {
 		go func(i int, last io.ReadCloser) {
 			defer wg.Done()
 			defer out.Close()
+			orig := &bytes.Buffer{}
 			add := &bytes.Buffer{}
+			bp := bg.Buildpacks[i]
+			var in io.Reader
 			if last != nil {
 				defer last.Close()
-				orig := &bytes.Buffer{}
-				last := io.TeeReader(last, orig)
-				codes[i] = bg.Buildpacks[i].Detect(c, last, add)
-				ioutil.ReadAll(last)
-				if codes[i] == CodeDetectPass {
-					mergeTOML(c.Err, out, orig, add)
-				} else {
-					mergeTOML(c.Err, out, orig)
+				in = io.TeeReader(last, orig)
+			}
+			start := time.Now()
+			results[i] = bp.detect(c, in, add)
+			elapsed := time.Since(start)
+			if in != nil {
+				ioutil.ReadAll(in)
+			}
+			plan, _ := mergeTOML(c.Err, c.Merge, orig.Bytes())
+			if results[i].code == CodeDetectPass {
+				merged, conflicts := mergeTOML(c.Err, c.Merge, orig.Bytes(), add.Bytes())
+				for _, conflict := range conflicts {
+					if c.Merge == MergeDeepStrict {
+						c.Err.Printf("Error: %s plan conflict: %s", bp.Name, conflict)
+					} else {
+						c.Err.Printf("Warning: %s plan conflict: %s", bp.Name, conflict)
+					}
 				}
-			} else {
-				codes[i] = bg.Buildpacks[i].Detect(c, nil, add)
-				if codes[i] == CodeDetectPass {
-					mergeTOML(c.Err, out, add)
+				if len(conflicts) > 0 && c.Merge == MergeDeepStrict {
+					results[i].code = CodeDetectError
+				} else {
+					plan = merged
 				}
 			}
+			reports[i] = bp.report(c, results[i], elapsed, add.Bytes())
+			if err := toml.NewEncoder(out).Encode(plan); err != nil {
+				c.Err.Print("Warning: ", err)
+			}
 		}(i, lastIn)
 		lastIn = in
 	}
@@ -181,32 +377,191 @@ func (bg *BuildpackGroup) pDetect(c *DetectConfig) (plan []byte, codes []int) {
 			plan = p
 		}
 	}
-	return plan, codes
+	return plan, results, reports
+}
+
+// report decodes the plan entries contributed by the buildpack before
+// they are merged, so that only its own entries are recorded.
+func (bp *Buildpack) report(c *DetectConfig, result detectResult, elapsed time.Duration, plan []byte) BuildpackDetectReport {
+	r := BuildpackDetectReport{
+		ID:           bp.ID,
+		Version:      bp.Version,
+		Optional:     bp.Optional,
+		Code:         result.code,
+		Incompatible: result.incompatible,
+		Seconds:      elapsed.Seconds(),
+		Output:       string(result.output),
+		Provides:     result.provides,
+		Requires:     result.requires,
+	}
+	if c.Report == nil || result.code != CodeDetectPass {
+		return r
+	}
+	if _, err := toml.Decode(string(plan), &r.Plan); err != nil {
+		c.Err.Print("Warning: ", err)
+	}
+	return r
 }
 
-func mergeTOML(l *log.Logger, out io.Writer, in ...io.Reader) {
-	result := map[string]interface{}{}
-	for _, r := range in {
+// planLifecycleKey is the table in each provided plan entry where the
+// lifecycle records which buildpack provides and which require the entry.
+// Buildpacks may not set it.
+const planLifecycleKey = "lifecycle"
+
+// resolvePlan adds the entries explicitly provided by the passing buildpacks
+// to the plan, recording which buildpack provides and which require each.
+// Each requirement must be provided by the same or an earlier buildpack,
+// and no two buildpacks may provide an entry or require different values
+// for the same entry key.
+func resolvePlan(l *log.Logger, plan []byte, bps []*Buildpack, results []detectResult) ([]byte, []string) {
+	m := Plan{}
+	if _, err := toml.Decode(string(plan), &m); err != nil {
+		return plan, []string{err.Error()}
+	}
+	var problems []string
+	providers := map[string]*Buildpack{}
+	// setBy records the buildpack that set each key of each entry
+	setBy := map[string]map[string]*Buildpack{}
+	for i, bp := range bps {
+		for _, name := range sortedPlanNames(results[i].provides) {
+			if _, ok := results[i].provides[name][planLifecycleKey]; ok {
+				problems = append(problems, fmt.Sprintf("%s provides '%s' with reserved key '%s'", bp.Name, name, planLifecycleKey))
+				continue
+			}
+			if p, ok := providers[name]; ok {
+				problems = append(problems, fmt.Sprintf("%s and %s both provide '%s'", p.Name, bp.Name, name))
+				continue
+			}
+			if _, ok := m[name]; ok {
+				problems = append(problems, fmt.Sprintf("%s provides '%s', which is already in the plan", bp.Name, name))
+				continue
+			}
+			providers[name] = bp
+			setBy[name] = map[string]*Buildpack{}
+			for k := range results[i].provides[name] {
+				setBy[name][k] = bp
+			}
+			entry := copyEntry(results[i].provides[name])
+			entry[planLifecycleKey] = map[string]interface{}{"provided-by": bp.ID}
+			m[name] = entry
+		}
+		for _, name := range sortedPlanNames(results[i].requires) {
+			if _, ok := results[i].requires[name][planLifecycleKey]; ok {
+				problems = append(problems, fmt.Sprintf("%s requires '%s' with reserved key '%s'", bp.Name, name, planLifecycleKey))
+				continue
+			}
+			if _, ok := providers[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s requires '%s', which is not provided by it or an earlier buildpack", bp.Name, name))
+				continue
+			}
+			entry := m[name]
+			for k, v := range results[i].requires[name] {
+				if s, ok := setBy[name][k]; ok && !reflect.DeepEqual(entry[k], v) {
+					problems = append(problems, fmt.Sprintf("%s and %s conflict on '%s.%s'", s.Name, bp.Name, name, k))
+					continue
+				}
+				setBy[name][k] = bp
+				entry[k] = v
+			}
+			meta := entry[planLifecycleKey].(map[string]interface{})
+			requiredBy, _ := meta["required-by"].([]string)
+			meta["required-by"] = append(requiredBy, bp.ID)
+		}
+	}
+	if len(problems) > 0 {
+		return plan, problems
+	}
+	out := &bytes.Buffer{}
+	if err := toml.NewEncoder(out).Encode(m); err != nil {
+		l.Print("Warning: ", err)
+		return plan, nil
+	}
+	return out.Bytes(), nil
+}
+
+func sortedPlanNames(m Plan) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyEntry(entry map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range entry {
+		out[k] = v
+	}
+	return out
+}
+
+func mergeTOML(l *log.Logger, merge PlanMerge, in ...[]byte) (result map[string]interface{}, conflicts []string) {
+	result = map[string]interface{}{}
+	for _, b := range in {
 		var m map[string]interface{}
-		if _, err := toml.DecodeReader(r, &m); err != nil {
+		if _, err := toml.Decode(string(b), &m); err != nil {
 			l.Print("Warning: ", err)
 			continue
 		}
 		for k, v := range m {
-			result[k] = v
+			conflicts = append(conflicts, merge.merge(result, k, v)...)
 		}
 	}
-	if err := toml.NewEncoder(out).Encode(result); err != nil {
-		l.Print("Warning: ", err)
+	return result, conflicts
+}
+
+// expand returns every group of buildpacks without orders that the group
+// stands for, in priority order. Each meta-buildpack is replaced by the
+// buildpacks in one of its groups, which are optional if it is optional.
+func (bg *BuildpackGroup) expand() []BuildpackGroup {
+	groups := []BuildpackGroup{{}}
+	for _, bp := range bg.Buildpacks {
+		if len(bp.Order) == 0 {
+			for i := range groups {
+				groups[i].Buildpacks = append(groups[i].Buildpacks, bp)
+			}
+			continue
+		}
+		var expanded []BuildpackGroup
+		for _, group := range groups {
+			for i := range bp.Order {
+				for _, sub := range bp.Order[i].expand() {
+					buildpacks := append([]*Buildpack{}, group.Buildpacks...)
+					for _, subBP := range sub.Buildpacks {
+						if bp.Optional && !subBP.Optional {
+							optional := *subBP
+							optional.Optional = true
+							subBP = &optional
+						}
+						buildpacks = append(buildpacks, subBP)
+					}
+					expanded = append(expanded, BuildpackGroup{Buildpacks: buildpacks})
+				}
+			}
+		}
+		groups = expanded
 	}
+	return groups
 }
 
 type BuildpackOrder []BuildpackGroup
 
+// Detect tries each group in the order, expanding meta-buildpacks, and
+// returns the first group that passes.
 func (bo BuildpackOrder) Detect(c *DetectConfig) (plan []byte, group *BuildpackGroup) {
 	for i := range bo {
-		if plan, group, ok := bo[i].Detect(c); ok {
-			return plan, group
+		for _, g := range bo[i].expand() {
+			if c.Context != nil && c.Context.Err() != nil {
+				c.Err.Printf("Error: detection stopped: %s", c.Context.Err())
+				return nil, nil
+			}
+			if plan, group, ok := g.Detect(c); ok {
+				if c.Report != nil {
+					c.Report.Groups[len(c.Report.Groups)-1].Selected = true
+				}
+				return plan, group
+			}
 		}
 	}
 	return nil, nil
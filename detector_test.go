@@ -2,6 +2,8 @@ package lifecycle_test
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -9,7 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -170,5 +174,405 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf("Unexpected error: %s\n", errLog)
 			}
 		})
+
+		it("should report every group tried when a report is requested", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+
+			config.Report = &lifecycle.DetectReport{}
+			list.Detect(config)
+
+			bpReport := func(id string, optional bool, code int, n string) lifecycle.BuildpackDetectReport {
+				r := lifecycle.BuildpackDetectReport{
+					ID:       id,
+					Optional: optional,
+					Code:     code,
+					Output:   "stdout: " + n + "\nstderr: " + n + "\n",
+				}
+				if code == lifecycle.CodeDetectPass {
+					r.Plan = lifecycle.Plan{n: {n: true}}
+				}
+				return r
+			}
+			for _, group := range config.Report.Groups {
+				for i := range group.Buildpacks {
+					if group.Buildpacks[i].Seconds <= 0 {
+						t.Fatalf("Unexpected duration: %f\n", group.Buildpacks[i].Seconds)
+					}
+					group.Buildpacks[i].Seconds = 0
+				}
+			}
+			if s := cmp.Diff(config.Report, &lifecycle.DetectReport{
+				Groups: []lifecycle.GroupDetectReport{
+					{
+						Buildpacks: []lifecycle.BuildpackDetectReport{
+							bpReport("buildpack1", false, 0, "1"),
+							bpReport("com.buildpack2", false, 0, "2"),
+							bpReport("buildpack/3", false, 0, "3"),
+							bpReport("buildpack-4", false, 100, "4"),
+						},
+					},
+					{
+						Buildpacks: []lifecycle.BuildpackDetectReport{
+							bpReport("", false, 0, "1"),
+							bpReport("", false, 0, "2"),
+							bpReport("", false, 0, "3"),
+							bpReport("", true, 100, "4"),
+						},
+						Pass:     true,
+						Selected: true,
+					},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected report:\n%s\n", s)
+			}
+		})
+
+		when("buildpacks provide and require plan entries", func() {
+			it.Before(func() {
+				mkfile(t, "1", filepath.Join(appDir, "add"))
+				mkfile(t, "3", filepath.Join(appDir, "last"))
+				for i := range list[1].Buildpacks {
+					list[1].Buildpacks[i].ID = fmt.Sprintf("buildpack%d", i+1)
+				}
+			})
+
+			mkplan := func(data, r, kind string) {
+				t.Helper()
+				mkdir(t, filepath.Join(platformDir, "plan", r, kind))
+				mkfile(t, data, filepath.Join(platformDir, "plan", r, kind, "dep"))
+			}
+
+			it("should record the provider and requirers of each entry", func() {
+				mkplan("version = \"1.0\"\nname = \"dep\"", "1", "provides")
+				mkplan("version = \"1.0\"", "2", "requires")
+				mkplan("version = \"1.0\"\nbuild = true", "3", "requires")
+
+				plan, group := list.Detect(config)
+				if group == nil {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+				var out lifecycle.Plan
+				if _, err := toml.Decode(string(plan), &out); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(out["dep"], map[string]interface{}{
+					"name":    "dep",
+					"version": "1.0",
+					"build":   true,
+					"lifecycle": map[string]interface{}{
+						"provided-by": "buildpack1",
+						"required-by": []interface{}{"buildpack2", "buildpack3"},
+					},
+				}); s != "" {
+					t.Fatalf("Unexpected plan entry:\n%s\n", s)
+				}
+				if _, ok := out["1"]; !ok {
+					t.Fatalf("Missing plan entry: %s\n", plan)
+				}
+			})
+
+			it("should fail the group when a requirement conflicts with the provided entry", func() {
+				mkplan("version = \"1.0\"\nname = \"dep\"", "1", "provides")
+				mkplan("version = \"2.0\"", "2", "requires")
+
+				if _, group := list.Detect(config); group == nil {
+					t.Fatal("Expected group.\n")
+				} else if s := cmp.Diff(*group, list[2]); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack1-name and buildpack2-name conflict on 'dep.version'\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+
+			it("should fail the group when a requirement is not provided", func() {
+				mkplan("", "2", "requires")
+				mkplan("", "3", "provides")
+
+				if _, group := list.Detect(config); group == nil {
+					t.Fatal("Expected group.\n")
+				} else if s := cmp.Diff(*group, list[2]); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack2-name requires 'dep', which is not provided by it or an earlier buildpack\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+
+			it("should fail the group when two buildpacks provide the same entry", func() {
+				mkplan("", "1", "provides")
+				mkplan("", "2", "provides")
+
+				if _, group := list.Detect(config); group == nil {
+					t.Fatal("Expected group.\n")
+				} else if s := cmp.Diff(*group, list[2]); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack1-name and buildpack2-name both provide 'dep'\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+
+			it("should fail the group when a buildpack sets the reserved lifecycle key", func() {
+				mkplan("[lifecycle]\nprovided-by = \"other\"", "1", "provides")
+
+				if _, group := list.Detect(config); group != nil {
+					t.Fatalf("Unexpected group: %#v\n", group)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack1-name provides 'dep' with reserved key 'lifecycle'\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+
+			it("should fail the group when requirements conflict", func() {
+				mkplan("", "1", "provides")
+				mkplan("version = \"1.0\"", "2", "requires")
+				mkplan("version = \"2.0\"", "3", "requires")
+
+				if _, group := list.Detect(config); group == nil {
+					t.Fatal("Expected group.\n")
+				} else if s := cmp.Diff(*group, list[2]); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack2-name and buildpack3-name conflict on 'dep.version'\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+		})
+
+		when("buildpacks contribute the same plan entry", func() {
+			it.Before(func() {
+				mkfile(t, "1", filepath.Join(appDir, "add"))
+				mkfile(t, "3", filepath.Join(appDir, "last"))
+			})
+
+			mkentry := func(data, r string) {
+				t.Helper()
+				mkdir(t, filepath.Join(platformDir, "plan", r))
+				mkfile(t, data, filepath.Join(platformDir, "plan", r, "dep"))
+			}
+
+			it("should replace the entry by default", func() {
+				mkentry("a = 1\n[t]\nx = 1", "1")
+				mkentry("b = 2\n[t]\ny = 2", "2")
+
+				plan, _ := list.Detect(config)
+				var out lifecycle.Plan
+				if _, err := toml.Decode(string(plan), &out); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(out["dep"], map[string]interface{}{
+					"b": int64(2),
+					"t": map[string]interface{}{"y": int64(2)},
+				}); s != "" {
+					t.Fatalf("Unexpected plan entry:\n%s\n", s)
+				}
+			})
+
+			it("should merge tables and append arrays with deep merge", func() {
+				config.Merge = lifecycle.MergeDeep
+				mkentry("a = 1\nl = [\"x\"]\n[t]\nx = 1", "1")
+				mkentry("a = 1\nl = [\"y\"]\n[t]\ny = 2", "2")
+				mkentry("a = 3", "3")
+
+				plan, _ := list.Detect(config)
+				var out lifecycle.Plan
+				if _, err := toml.Decode(string(plan), &out); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(out["dep"], map[string]interface{}{
+					"a": int64(3),
+					"l": []interface{}{"x", "y"},
+					"t": map[string]interface{}{"x": int64(1), "y": int64(2)},
+				}); s != "" {
+					t.Fatalf("Unexpected plan entry:\n%s\n", s)
+				}
+				if !strings.Contains(errLog.String(), "Warning: buildpack3-name plan conflict: 'dep.a' is both 1 and 3\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+
+			it("should fail a buildpack that conflicts with strict deep merge", func() {
+				config.Merge = lifecycle.MergeDeepStrict
+				mkentry("a = 1", "1")
+				mkentry("a = 2", "2")
+
+				if _, group := list.Detect(config); group == nil {
+					t.Fatal("Expected group.\n")
+				} else if s := cmp.Diff(*group, list[2]); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(outLog.String(), "buildpack2-name: error (1)\n") {
+					t.Fatalf("Unexpected log: %s\n", outLog)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack2-name plan conflict: 'dep.a' is both 1 and 2\n") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			})
+		})
+
+		it("should expand meta-buildpacks into each of their groups in order", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+
+			buildpackDir := filepath.Join("testdata", "buildpack")
+			bp := func(n int) *lifecycle.Buildpack {
+				return &lifecycle.Buildpack{ID: fmt.Sprintf("buildpack%d", n), Name: fmt.Sprintf("buildpack%d-name", n), Dir: buildpackDir}
+			}
+			meta := &lifecycle.Buildpack{ID: "meta", Order: lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp(2), bp(3), bp(4)}},
+				{Buildpacks: []*lifecycle.Buildpack{bp(2), {ID: "inner-meta", Order: lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{bp(3), bp(4)}},
+					{Buildpacks: []*lifecycle.Buildpack{bp(3)}},
+				}}}},
+			}}
+			optionalMeta := &lifecycle.Buildpack{ID: "optional-meta", Optional: true, Order: lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp(5)}},
+			}}
+			list = lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp(1), meta, optionalMeta}},
+			}
+
+			_, group := list.Detect(config)
+			if group == nil {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			if s := cmp.Diff(*group, lifecycle.BuildpackGroup{
+				Buildpacks: []*lifecycle.Buildpack{bp(1), bp(2), bp(3)},
+			}); s != "" {
+				t.Fatalf("Unexpected group:\n%s\n", s)
+			}
+			if strings.Count(outLog.String(), "Trying group of 5...") != 2 ||
+				strings.Count(outLog.String(), "Trying group of 4...") != 1 ||
+				!strings.HasSuffix(outLog.String(), "buildpack3-name: pass\nbuildpack5-name: skip\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+		})
+
+		it("should exclude buildpacks that do not support the stack", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+
+			config.StackID = "some-stack"
+			list[0].Buildpacks[0].Stacks = []string{"some-stack"}
+			list[1].Buildpacks[1].Stacks = []string{"other-stack"}
+			list[2].Buildpacks[0].Stacks = []string{"other-stack", "*"}
+			list[3].Buildpacks[2].Stacks = []string{"other-stack"}
+			list[4].Buildpacks[0].Stacks = []string{"other-stack"}
+
+			if _, group := list.Detect(config); group == nil {
+				t.Fatal("Expected group.\n")
+			} else if s := cmp.Diff(*group, list[2]); s != "" {
+				t.Fatalf("Unexpected group:\n%s\n", s)
+			}
+			if !strings.Contains(outLog.String(),
+				"======== Results ========\n"+
+					"buildpack1-name: pass\nbuildpack2-name: incompatible with stack 'some-stack'\n",
+			) {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			if strings.Contains(outLog.String(), "======== Output: buildpack2-name ========\nstdout: 2\nstderr: 2\n======== Results") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+		})
+
+		it("should provide allowed platform env vars when requested", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+			mkfile(t, "some-value", filepath.Join(platformDir, "env", "PLATFORM_TEST.override"))
+
+			list.Detect(config)
+			if strings.Contains(outLog.String(), "platform: ") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+
+			outLog.Reset()
+			config.PlatformEnv = &lifecycle.PlatformEnv{Deny: []string{"PLATFORM_*"}}
+			list.Detect(config)
+			if strings.Contains(outLog.String(), "platform: ") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+
+			outLog.Reset()
+			config.PlatformEnv = &lifecycle.PlatformEnv{Allow: []string{"PLATFORM_TEST"}}
+			list.Detect(config)
+			if !strings.Contains(outLog.String(), "stdout: 1\nplatform: some-value\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+		})
+
+		when("a buildpack does not finish before its timeout", func() {
+			it.Before(func() {
+				mkfile(t, "1", filepath.Join(appDir, "add"))
+				mkfile(t, "3", filepath.Join(appDir, "last"))
+				mkfile(t, "2", filepath.Join(platformDir, "env", "HANG"))
+			})
+
+			testTimeout := func() {
+				t.Helper()
+				start := time.Now()
+				_, group := list.Detect(config)
+				if elapsed := time.Since(start); elapsed > 10*time.Second {
+					t.Fatalf("Detection took too long: %s\n", elapsed)
+				}
+				if s := cmp.Diff(*group, list[2]); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !strings.Contains(outLog.String(), "buildpack2-name: timeout\n") {
+					t.Fatalf("Unexpected log: %s\n", outLog)
+				}
+				if !strings.Contains(errLog.String(), "Error: buildpack2-name detection stopped: context deadline exceeded") {
+					t.Fatalf("Unexpected error: %s\n", errLog)
+				}
+			}
+
+			it("should kill it and try the next group when the global timeout is exceeded", func() {
+				config.Timeout = 200 * time.Millisecond
+				testTimeout()
+			})
+
+			it("should kill it and try the next group when the buildpack timeout is exceeded", func() {
+				for _, group := range list {
+					for _, bp := range group.Buildpacks {
+						bp.DetectTimeout = 200 * time.Millisecond
+					}
+				}
+				config.Timeout = time.Hour
+				testTimeout()
+			})
+		})
+
+		it("should not report a buildpack that exits with the timeout(1) status as timed out", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+			mkfile(t, "124", filepath.Join(platformDir, "env", "EXIT"))
+			if _, group := list.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if !strings.Contains(outLog.String(), "buildpack1-name: error (124)\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			if strings.Contains(outLog.String(), ": timeout\n") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+		})
+
+		it("should stop trying groups when the context is canceled", func() {
+			mkfile(t, "1", filepath.Join(appDir, "add"))
+			mkfile(t, "3", filepath.Join(appDir, "last"))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			config.Context = ctx
+			if _, group := list.Detect(config); group != nil {
+				t.Fatalf("Unexpected group: %#v\n", group)
+			}
+			if strings.Contains(outLog.String(), "Trying group") {
+				t.Fatalf("Unexpected log: %s\n", outLog)
+			}
+			if !strings.Contains(errLog.String(), "Error: detection stopped: context canceled") {
+				t.Fatalf("Unexpected error: %s\n", errLog)
+			}
+		})
 	})
 }
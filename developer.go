@@ -0,0 +1,97 @@
+package lifecycle
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Developer struct {
+	PlatformDir        string
+	CacheDir           string
+	LaunchDir          string
+	AppDir             string
+	DefaultProcessType string
+	Env                BuildEnv
+	Buildpacks         []*Buildpack
+	Plan               Plan
+	Out, Err           io.Writer
+	Exec               func(argv0 string, argv []string, envv []string) error
+}
+
+// Develop builds the app and then runs it like Launch, using the build env.
+// Direct processes are executed without a shell, and receive the rest of
+// args after their own args.
+func (d *Developer) Develop(args []string) error {
+	builder := &Builder{
+		PlatformDir: d.PlatformDir,
+		CacheDir:    d.CacheDir,
+		LaunchDir:   d.LaunchDir,
+		AppDir:      d.AppDir,
+		Env:         d.Env,
+		Buildpacks:  d.Buildpacks,
+		Plan:        d.Plan,
+		Out:         d.Out,
+		Err:         d.Err,
+	}
+	metadata, err := builder.run("develop")
+	if err != nil {
+		return errors.Wrap(err, "develop")
+	}
+
+	launcher := &Launcher{
+		DefaultProcessType: d.DefaultProcessType,
+		Processes:          metadata.Processes,
+	}
+	process, err := launcher.processFor(args)
+	if err != nil {
+		return errors.Wrap(err, "determine start command")
+	}
+
+	if err := os.Chdir(d.AppDir); err != nil {
+		return errors.Wrap(err, "change to app directory")
+	}
+	envv := d.Env.List()
+	if process.Direct {
+		binary, err := lookPath(process.Command, envv)
+		if err != nil {
+			return errors.Wrap(err, "find start command")
+		}
+		argv := append([]string{process.Command}, process.Args...)
+		if err := d.Exec(binary, argv, envv); err != nil {
+			return errors.Wrap(err, "exec")
+		}
+		return nil
+	}
+	if err := d.Exec("/bin/bash", []string{"bash", "-c", process.Command}, envv); err != nil {
+		return errors.Wrap(err, "exec")
+	}
+	return nil
+}
+
+// lookPath is exec.LookPath for the PATH in envv instead of the PATH of
+// the current process.
+func lookPath(file string, envv []string) (string, error) {
+	if strings.Contains(file, "/") {
+		return exec.LookPath(file)
+	}
+	var path string
+	for _, kv := range envv {
+		if strings.HasPrefix(kv, "PATH=") {
+			path = strings.TrimPrefix(kv, "PATH=")
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if binary, err := exec.LookPath(dir + string(filepath.Separator) + file); err == nil {
+			return binary, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
@@ -0,0 +1,190 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/testmock"
+)
+
+func TestDeveloper(t *testing.T) {
+	spec.Run(t, "Developer", testDeveloper, spec.Report(report.Terminal{}))
+}
+
+func testDeveloper(t *testing.T, when spec.G, it spec.S) {
+	var (
+		developer      *lifecycle.Developer
+		mockCtrl       *gomock.Controller
+		env            *testmock.MockBuildEnv
+		stdout, stderr *bytes.Buffer
+		tmpDir         string
+		wd             string
+		appDir         string
+		cacheDir       string
+		execArgs       []syscallExecArgs
+	)
+
+	it.Before(func() {
+		mockCtrl = gomock.NewController(t)
+		env = testmock.NewMockBuildEnv(mockCtrl)
+
+		var err error
+		wd, err = os.Getwd()
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		tmpDir, err = ioutil.TempDir("", "lifecycle")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		platformDir := filepath.Join(tmpDir, "platform")
+		launchDir := filepath.Join(tmpDir, "launch")
+		cacheDir = filepath.Join(tmpDir, "cache")
+		appDir = filepath.Join(launchDir, "app")
+		mkdir(t, cacheDir, launchDir, appDir, filepath.Join(platformDir, "env"))
+
+		execArgs = nil
+		buildpackDir := filepath.Join("testdata", "buildpack")
+		developer = &lifecycle.Developer{
+			PlatformDir:        platformDir,
+			CacheDir:           cacheDir,
+			LaunchDir:          launchDir,
+			AppDir:             appDir,
+			DefaultProcessType: "web",
+			Env:                env,
+			Buildpacks: []*lifecycle.Buildpack{
+				{ID: "buildpack1-id", Dir: buildpackDir},
+				{ID: "buildpack2-id", Dir: buildpackDir},
+			},
+			Plan: lifecycle.Plan{
+				"dep1": {"v": "1"},
+				"dep2": {"v": "2"},
+			},
+			Out: io.MultiWriter(stdout, it.Out()),
+			Err: io.MultiWriter(stderr, it.Out()),
+			Exec: func(argv0 string, argv []string, envv []string) error {
+				execArgs = append(execArgs, syscallExecArgs{argv0: argv0, argv: argv, envv: envv})
+				return nil
+			},
+		}
+	})
+
+	it.After(func() {
+		os.Chdir(wd)
+		os.RemoveAll(tmpDir)
+		mockCtrl.Finish()
+	})
+
+	when("#Develop", func() {
+		it.Before(func() {
+			env.EXPECT().List().Return([]string{"ID=1"})
+			env.EXPECT().List().Return([]string{"ID=2"})
+		})
+
+		it("should run bin/develop for each buildpack and process the cache dirs", func() {
+			mkdir(t,
+				filepath.Join(appDir, "develop-cache-buildpack1", "cache-layer1"),
+				filepath.Join(appDir, "develop-cache-buildpack2", "cache-layer2"),
+			)
+			env.EXPECT().List().Return([]string{"SOME=env"})
+			gomock.InOrder(
+				env.EXPECT().AddRootDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer1")),
+				env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer1", "env")),
+				env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack1-id", "cache-layer1", "env.build")),
+				env.EXPECT().AddRootDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer2")),
+				env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer2", "env")),
+				env.EXPECT().AddEnvDir(filepath.Join(cacheDir, "buildpack2-id", "cache-layer2", "env.build")),
+			)
+			if err := developer.Develop(nil); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if stdout.String() != "DEVELOP-STDOUT1\nDEVELOP-STDOUT2\n" {
+				t.Fatalf("Unexpected: %s", stdout)
+			}
+			if stderr.String() != "DEVELOP-STDERR1\nDEVELOP-STDERR2\n" {
+				t.Fatalf("Unexpected: %s", stderr)
+			}
+			testPlan(t, lifecycle.Plan{"dep1": {"v": "1"}, "dep2": {"v": "2"}},
+				filepath.Join(appDir, "develop-plan1.toml"),
+				filepath.Join(appDir, "develop-plan2.toml"),
+			)
+		})
+
+		it("should exec the default process with the build env", func() {
+			env.EXPECT().List().Return([]string{"SOME=env"})
+			if err := developer.Develop(nil); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if cwd, err := os.Getwd(); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			} else if s := cmp.Diff(cwd, appDir); s != "" {
+				t.Fatalf("Unexpected working directory:\n%s\n", s)
+			}
+			if s := cmp.Diff(execArgs, []syscallExecArgs{{
+				argv0: "/bin/bash",
+				argv:  []string{"bash", "-c", "develop-web2-command"},
+				envv:  []string{"SOME=env"},
+			}}, cmp.AllowUnexported(syscallExecArgs{})); s != "" {
+				t.Fatalf("Unexpected exec:\n%s\n", s)
+			}
+		})
+
+		it("should exec the provided process type", func() {
+			env.EXPECT().List().Return([]string{"SOME=env"})
+			if err := developer.Develop([]string{"develop1-type"}); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if len(execArgs) != 1 || execArgs[0].argv[2] != "develop1-command" {
+				t.Fatalf("Unexpected exec: %+v\n", execArgs)
+			}
+		})
+
+		it("should exec a direct process found on the build PATH without a shell", func() {
+			binDir := filepath.Join(tmpDir, "bin")
+			mkdir(t, binDir)
+			mkfile(t, "#!/bin/sh\n", filepath.Join(binDir, "develop1-direct-command"))
+			env.EXPECT().List().Return([]string{"PATH=" + binDir})
+			if err := developer.Develop([]string{"develop1-direct-type", "extra arg"}); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(execArgs, []syscallExecArgs{{
+				argv0: filepath.Join(binDir, "develop1-direct-command"),
+				argv:  []string{"develop1-direct-command", "some arg", "other-arg", "extra arg"},
+				envv:  []string{"PATH=" + binDir},
+			}}, cmp.AllowUnexported(syscallExecArgs{})); s != "" {
+				t.Fatalf("Unexpected exec:\n%s\n", s)
+			}
+		})
+
+		it("should return an error when a direct process is not on the build PATH", func() {
+			env.EXPECT().List().Return([]string{"PATH=" + filepath.Join(tmpDir, "bin")})
+			if err := developer.Develop([]string{"develop1-direct-type"}); err == nil {
+				t.Fatal("Expected error.\n")
+			}
+			if len(execArgs) != 0 {
+				t.Fatalf("Unexpected exec: %+v\n", execArgs)
+			}
+		})
+
+		it("should return an error when the default process type is missing", func() {
+			developer.DefaultProcessType = "missing"
+			if err := developer.Develop(nil); err == nil {
+				t.Fatal("Expected error.\n")
+			}
+			if len(execArgs) != 0 {
+				t.Fatalf("Unexpected exec: %+v\n", execArgs)
+			}
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -45,22 +46,78 @@ func prefix(s string, prefix byte) string {
 }
 
 func (p *Env) AddEnvDir(envDir string) error {
-	return eachEnvFile(envDir, func(k, v string) error {
+	return p.AddFilteredEnvDir(envDir, nil)
+}
+
+// AddFilteredEnvDir is AddEnvDir for only the variables that keep returns
+// true for. A nil keep function keeps every variable.
+//
+// Each file in envDir is named NAME or NAME.<action>, where the action is
+// override, default (set only if unset), prepend, or append. Prepend places
+// the value before the existing value, and append places it after. A
+// NAME.delim file sets the delimiter used by prepend and append, which is
+// otherwise empty. Files without an action prepend using the delimiter or,
+// if there is none, os.PathListSeparator.
+func (p *Env) AddFilteredEnvDir(envDir string, keep func(name string) bool) error {
+	type envFile struct{ name, action, value string }
+	var files []envFile
+	delims := map[string]string{}
+	if err := eachEnvFile(envDir, func(k, v string) error {
 		parts := strings.SplitN(k, ".", 2)
 		name := parts[0]
 		var action string
 		if len(parts) > 1 {
 			action = strings.ToLower(parts[1])
 		}
-		switch action {
-		case "append":
-			return p.Setenv(name, v+p.Getenv(name))
-		case "override":
-			return p.Setenv(name, v)
-		default:
-			return p.Setenv(name, v+prefix(p.Getenv(name), os.PathListSeparator))
+		if action == "delim" {
+			delims[name] = v
+			return nil
+		}
+		files = append(files, envFile{name, action, v})
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if keep != nil && !keep(f.name) {
+			continue
+		}
+		if err := p.addEnvFile(f.name, f.action, f.value, delims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Env) addEnvFile(name, action, v string, delims map[string]string) error {
+	delim, hasDelim := delims[name]
+	old := p.Getenv(name)
+	switch action {
+	case "override":
+		return p.Setenv(name, v)
+	case "default":
+		if old != "" {
+			return nil
+		}
+		return p.Setenv(name, v)
+	case "prepend":
+		return p.Setenv(name, join(v, delim, old))
+	case "append":
+		return p.Setenv(name, join(old, delim, v))
+	default:
+		if !hasDelim {
+			delim = string(os.PathListSeparator)
 		}
-	})
+		return p.Setenv(name, join(v, delim, old))
+	}
+}
+
+// join joins a and b with delim, omitting delim if either is empty.
+func join(a, delim, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + delim + b
 }
 
 func eachEnvFile(dir string, fn func(k, v string) error) error {
@@ -88,3 +145,137 @@ func eachEnvFile(dir string, fn func(k, v string) error) error {
 func (p *Env) List() []string {
 	return p.Environ()
 }
+
+// MemoryEnv is a BuildEnv that keeps variables in memory instead of the
+// process environment. Changes are recorded on top of a shared snapshot,
+// so that copies of a MemoryEnv may be used by concurrent builds.
+type MemoryEnv struct {
+	Map map[string][]string
+
+	base    map[string]string
+	vars    map[string]string
+	origins map[string][]string
+}
+
+// NewMemoryEnv returns a MemoryEnv that starts with the variables in environ,
+// given as key=value pairs (e.g., from os.Environ).
+func NewMemoryEnv(environ []string, m map[string][]string) *MemoryEnv {
+	base := map[string]string{}
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			base[parts[0]] = parts[1]
+		} else {
+			base[parts[0]] = ""
+		}
+	}
+	return &MemoryEnv{Map: m, base: base}
+}
+
+// Copy returns a MemoryEnv with the current variables and no recorded origins.
+// The copy shares unmodified variables with e.
+func (e *MemoryEnv) Copy() *MemoryEnv {
+	base := e.base
+	if len(e.vars) > 0 {
+		base = map[string]string{}
+		for k, v := range e.base {
+			base[k] = v
+		}
+		for k, v := range e.vars {
+			base[k] = v
+		}
+	}
+	return &MemoryEnv{Map: e.Map, base: base}
+}
+
+func (e *MemoryEnv) Getenv(key string) string {
+	if v, ok := e.vars[key]; ok {
+		return v
+	}
+	return e.base[key]
+}
+
+// Origins returns the directories that set the variable, in order.
+// Each directory is a layer or layer env directory of a buildpack.
+func (e *MemoryEnv) Origins(key string) []string {
+	return append([]string(nil), e.origins[key]...)
+}
+
+func (e *MemoryEnv) AddRootDir(baseDir string) error {
+	return e.env(baseDir).AddRootDir(baseDir)
+}
+
+func (e *MemoryEnv) AddEnvDir(envDir string) error {
+	return e.env(envDir).AddEnvDir(envDir)
+}
+
+func (e *MemoryEnv) AddFilteredEnvDir(envDir string, keep func(name string) bool) error {
+	return e.env(envDir).AddFilteredEnvDir(envDir, keep)
+}
+
+// env returns an Env that records dir as the origin of each variable it sets.
+func (e *MemoryEnv) env(dir string) *Env {
+	return &Env{
+		Getenv: e.Getenv,
+		Setenv: func(key, value string) error {
+			if e.vars == nil {
+				e.vars = map[string]string{}
+				e.origins = map[string][]string{}
+			}
+			e.vars[key] = value
+			e.origins[key] = append(e.origins[key], dir)
+			return nil
+		},
+		Environ: e.List,
+		Map:     e.Map,
+	}
+}
+
+func (e *MemoryEnv) List() []string {
+	var keys []string
+	for k := range e.base {
+		if _, ok := e.vars[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for k := range e.vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k+"="+e.Getenv(k))
+	}
+	return out
+}
+
+// PlatformEnv selects the variables in the platform env directory that the
+// lifecycle provides to buildpacks. Names may be patterns (e.g., HTTP*_PROXY)
+// as accepted by filepath.Match.
+type PlatformEnv struct {
+	// Allow, if not empty, lists the only variables that are provided.
+	Allow []string
+	// Deny lists variables that are never provided.
+	Deny []string
+}
+
+func (p *PlatformEnv) Allows(name string) bool {
+	if matchAny(p.Deny, name) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchAny(p.Allow, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTo adds the allowed variables in <platformDir>/env to env.
+func (p *PlatformEnv) AddTo(env BuildEnv, platformDir string) error {
+	return env.AddFilteredEnvDir(filepath.Join(platformDir, "env"), p.Allows)
+}
@@ -131,7 +131,7 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 			if s := cmp.Diff(result, map[string]string{
 				"SOME_VAR_DEFAULT":      "some-value-default:some-value-default-orig",
 				"SOME_VAR_DEFAULT_NEW":  "some-value-default",
-				"SOME_VAR_APPEND":       "some-value-appendsome-value-append-orig",
+				"SOME_VAR_APPEND":       "some-value-append-origsome-value-append",
 				"SOME_VAR_APPEND_NEW":   "some-value-append",
 				"SOME_VAR_OVERRIDE":     "some-value-override",
 				"SOME_VAR_OVERRIDE_NEW": "some-value-override",
@@ -141,6 +141,65 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should set defaults, prepend, and use delimiters", func() {
+			mkfile(t, "some-value-default", filepath.Join(tmpDir, "SOME_VAR_SET.default"), filepath.Join(tmpDir, "SOME_VAR_UNSET.default"))
+			mkfile(t, "some-value-prepend", filepath.Join(tmpDir, "SOME_VAR_PREPEND.prepend"), filepath.Join(tmpDir, "SOME_VAR_PREPEND_DELIM.prepend"))
+			mkfile(t, "-Xmx1g", filepath.Join(tmpDir, "JAVA_OPTS.append"))
+			mkfile(t, "some-value", filepath.Join(tmpDir, "SOME_PATH"))
+			mkfile(t, " ", filepath.Join(tmpDir, "JAVA_OPTS.delim"), filepath.Join(tmpDir, "SOME_VAR_PREPEND_DELIM.delim"))
+			mkfile(t, ";", filepath.Join(tmpDir, "SOME_PATH.delim"))
+			result = map[string]string{
+				"SOME_VAR_SET":           "some-value-orig",
+				"SOME_VAR_PREPEND":       "some-value-orig",
+				"SOME_VAR_PREPEND_DELIM": "some-value-orig",
+				"JAVA_OPTS":              "-Xss1m",
+				"SOME_PATH":              "some-path-orig",
+			}
+			if err := env.AddEnvDir(tmpDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(result, map[string]string{
+				"SOME_VAR_SET":           "some-value-orig",
+				"SOME_VAR_UNSET":         "some-value-default",
+				"SOME_VAR_PREPEND":       "some-value-prependsome-value-orig",
+				"SOME_VAR_PREPEND_DELIM": "some-value-prepend some-value-orig",
+				"JAVA_OPTS":              "-Xss1m -Xmx1g",
+				"SOME_PATH":              "some-value;some-path-orig",
+			}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
+		it("should prepend and append on opposite sides of the existing value", func() {
+			mkfile(t, "some-value", filepath.Join(tmpDir, "SOME_VAR_PREPEND.prepend"), filepath.Join(tmpDir, "SOME_VAR_APPEND.append"))
+			mkfile(t, ":", filepath.Join(tmpDir, "SOME_VAR_PREPEND.delim"), filepath.Join(tmpDir, "SOME_VAR_APPEND.delim"))
+			result = map[string]string{
+				"SOME_VAR_PREPEND": "some-value-orig",
+				"SOME_VAR_APPEND":  "some-value-orig",
+			}
+			if err := env.AddEnvDir(tmpDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(result, map[string]string{
+				"SOME_VAR_PREPEND": "some-value:some-value-orig",
+				"SOME_VAR_APPEND":  "some-value-orig:some-value",
+			}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
+		it("should skip variables that are filtered out", func() {
+			mkfile(t, "some-value", filepath.Join(tmpDir, "SOME_VAR.override"), filepath.Join(tmpDir, "OTHER_VAR"))
+			if err := env.AddFilteredEnvDir(tmpDir, func(name string) bool {
+				return name == "SOME_VAR"
+			}); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(result, map[string]string{"SOME_VAR": "some-value"}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
 		it("should return an error when setenv fails", func() {
 			retErr = errors.New("some error")
 			mkfile(t, "some-value", filepath.Join(tmpDir, "SOME_VAR"))
@@ -150,3 +209,142 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 }
+
+func TestPlatformEnv(t *testing.T) {
+	spec.Run(t, "PlatformEnv", testPlatformEnv, spec.Report(report.Terminal{}))
+}
+
+func testPlatformEnv(t *testing.T, when spec.G, it spec.S) {
+	when("#Allows", func() {
+		it("should allow every variable by default", func() {
+			if !(&lifecycle.PlatformEnv{}).Allows("SOME_VAR") {
+				t.Fatal("Expected variable to be allowed.\n")
+			}
+		})
+
+		it("should allow only matching variables that are not denied", func() {
+			p := &lifecycle.PlatformEnv{
+				Allow: []string{"*_PROXY", "SOME_VAR"},
+				Deny:  []string{"FTP_*"},
+			}
+			for name, allowed := range map[string]bool{
+				"HTTP_PROXY": true,
+				"SOME_VAR":   true,
+				"FTP_PROXY":  false,
+				"OTHER_VAR":  false,
+			} {
+				if p.Allows(name) != allowed {
+					t.Fatalf("Unexpected result for %s.\n", name)
+				}
+			}
+		})
+	})
+}
+
+func TestMemoryEnv(t *testing.T) {
+	spec.Run(t, "MemoryEnv", testMemoryEnv, spec.Report(report.Terminal{}))
+}
+
+func testMemoryEnv(t *testing.T, when spec.G, it spec.S) {
+	var (
+		env    *lifecycle.MemoryEnv
+		tmpDir string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		env = lifecycle.NewMemoryEnv([]string{"PATH=some", "SOME_VAR=some-value", "EMPTY_VAR"}, map[string][]string{
+			"bin": {"PATH"},
+		})
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("should start from the snapshot", func() {
+		if s := cmp.Diff(env.List(), []string{"EMPTY_VAR=", "PATH=some", "SOME_VAR=some-value"}); s != "" {
+			t.Fatalf("Unexpected env:\n%s\n", s)
+		}
+	})
+
+	it("should set variables without modifying the process environment", func() {
+		mkdir(t, filepath.Join(tmpDir, "layer", "bin"), filepath.Join(tmpDir, "layer", "env"))
+		mkfile(t, "some-override", filepath.Join(tmpDir, "layer", "env", "SOME_VAR.override"))
+		mkfile(t, "lifecycle-test", filepath.Join(tmpDir, "layer", "env", "LIFECYCLE_MEMORY_ENV_TEST"))
+		path := os.Getenv("PATH")
+
+		if err := env.AddRootDir(filepath.Join(tmpDir, "layer")); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		if err := env.AddEnvDir(filepath.Join(tmpDir, "layer", "env")); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		if s := cmp.Diff(env.List(), []string{
+			"EMPTY_VAR=",
+			"LIFECYCLE_MEMORY_ENV_TEST=lifecycle-test",
+			"PATH=" + filepath.Join(tmpDir, "layer", "bin") + ":some",
+			"SOME_VAR=some-override",
+		}); s != "" {
+			t.Fatalf("Unexpected env:\n%s\n", s)
+		}
+		if os.Getenv("PATH") != path || os.Getenv("LIFECYCLE_MEMORY_ENV_TEST") != "" {
+			t.Fatal("Unexpected change to process environment.\n")
+		}
+	})
+
+	it("should record the directories that set each variable", func() {
+		mkdir(t,
+			filepath.Join(tmpDir, "layer1", "bin"),
+			filepath.Join(tmpDir, "layer2", "bin"),
+			filepath.Join(tmpDir, "layer2", "env"),
+		)
+		mkfile(t, "some-override", filepath.Join(tmpDir, "layer2", "env", "SOME_VAR.override"))
+		for _, dir := range []string{"layer1", "layer2"} {
+			if err := env.AddRootDir(filepath.Join(tmpDir, dir)); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+		}
+		if err := env.AddEnvDir(filepath.Join(tmpDir, "layer2", "env")); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		if s := cmp.Diff(env.Origins("PATH"), []string{
+			filepath.Join(tmpDir, "layer1"),
+			filepath.Join(tmpDir, "layer2"),
+		}); s != "" {
+			t.Fatalf("Unexpected origins:\n%s\n", s)
+		}
+		if s := cmp.Diff(env.Origins("SOME_VAR"), []string{filepath.Join(tmpDir, "layer2", "env")}); s != "" {
+			t.Fatalf("Unexpected origins:\n%s\n", s)
+		}
+		if len(env.Origins("EMPTY_VAR")) != 0 {
+			t.Fatalf("Unexpected origins: %s\n", env.Origins("EMPTY_VAR"))
+		}
+	})
+
+	when("#Copy", func() {
+		it("should return an independent env with the current variables", func() {
+			mkdir(t, filepath.Join(tmpDir, "layer1", "bin"), filepath.Join(tmpDir, "layer2", "bin"))
+			if err := env.AddRootDir(filepath.Join(tmpDir, "layer1")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			envCopy := env.Copy()
+			if err := envCopy.AddRootDir(filepath.Join(tmpDir, "layer2")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(env.Getenv("PATH"), filepath.Join(tmpDir, "layer1", "bin")+":some"); s != "" {
+				t.Fatalf("Unexpected PATH:\n%s\n", s)
+			}
+			if s := cmp.Diff(envCopy.Getenv("PATH"), filepath.Join(tmpDir, "layer2", "bin")+":"+filepath.Join(tmpDir, "layer1", "bin")+":some"); s != "" {
+				t.Fatalf("Unexpected PATH:\n%s\n", s)
+			}
+			if s := cmp.Diff(envCopy.Origins("PATH"), []string{filepath.Join(tmpDir, "layer2")}); s != "" {
+				t.Fatalf("Unexpected origins:\n%s\n", s)
+			}
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package lifecycle
 
 import (
+	"archive/tar"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -9,7 +10,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/docker/docker/pkg/archive"
@@ -27,13 +30,47 @@ type Exporter struct {
 	In           []byte
 	Out, Err     io.Writer
 	UID, GID     int
+
+	// SourceDate enables reproducible export when set. Layer tars are
+	// written with sorted entries and normalized headers, and SourceDate
+	// is used for every timestamp, including the image creation time.
+	SourceDate *time.Time
+}
+
+type ExportReport struct {
+	Image      ImageReport       `toml:"image" json:"image"`
+	RunImage   RunImageReport    `toml:"run-image" json:"runImage"`
+	Buildpacks []BuildpackReport `toml:"buildpacks" json:"buildpacks"`
+}
+
+type ImageReport struct {
+	Tags    []string `toml:"tags" json:"tags"`
+	Digest  string   `toml:"digest" json:"digest"`
+	ImageID string   `toml:"image-id" json:"imageID"`
+}
+
+type RunImageReport struct {
+	Digest   string `toml:"digest" json:"digest"`
+	TopLayer string `toml:"top-layer" json:"topLayer"`
+}
+
+type BuildpackReport struct {
+	ID      string        `toml:"id" json:"id"`
+	Version string        `toml:"version" json:"version"`
+	Layers  []LayerReport `toml:"layers" json:"layers"`
+}
+
+type LayerReport struct {
+	Name   string `toml:"name" json:"name"`
+	DiffID string `toml:"diff-id" json:"diffID"`
+	Reused bool   `toml:"reused" json:"reused"`
 }
 
 func (e *Exporter) Export(launchDirSrc, launchDirDst, appDirSrc, appDirDst string, runImage, origImage v1.Image) (v1.Image, error) {
 	if err := e.PrepareExport(launchDirSrc, launchDirDst, appDirSrc, appDirDst); err != nil {
 		return nil, errors.Wrapf(err, "prepare export")
 	}
-	image, err := e.ExportImage(launchDirDst, appDirDst, runImage, origImage)
+	image, _, err := e.ExportImage(launchDirDst, appDirDst, runImage, origImage)
 	if err != nil {
 		return nil, errors.Wrap(err, "export image")
 	}
@@ -102,84 +139,127 @@ func rawSHA(prefixedSHA string) string {
 	return strings.TrimPrefix(prefixedSHA, "sha256:")
 }
 
-func (e *Exporter) ExportImage(launchDirDst, appDirDst string, runImage, origImage v1.Image) (v1.Image, error) {
+func (e *Exporter) ExportImage(launchDirDst, appDirDst string, runImage, origImage v1.Image) (v1.Image, *ExportReport, error) {
 	data, err := ioutil.ReadFile(filepath.Join(e.ArtifactsDir, "metadata.json"))
 	if err != nil {
-		return nil, errors.Wrap(err, "read metadata")
+		return nil, nil, errors.Wrap(err, "read metadata")
 	}
 
 	var metadata AppImageMetadata
 	if err := json.Unmarshal(data, &metadata); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := addRunImageMetadata(runImage, &metadata); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	report := &ExportReport{
+		RunImage: RunImageReport{
+			Digest:   metadata.RunImage.SHA,
+			TopLayer: metadata.RunImage.TopLayer,
+		},
 	}
 
 	repoImage, _, err := img.Append(runImage, filepath.Join(e.ArtifactsDir, fmt.Sprintf("%s.tar", rawSHA(metadata.App.SHA))))
 	if err != nil {
-		return nil, errors.Wrap(err, "append app layer")
+		return nil, nil, errors.Wrap(err, "append app layer")
 	}
 	repoImage, _, err = img.Append(repoImage, filepath.Join(e.ArtifactsDir, fmt.Sprintf("%s.tar", rawSHA(metadata.Config.SHA))))
 	if err != nil {
-		return nil, errors.Wrap(err, "append config layer")
+		return nil, nil, errors.Wrap(err, "append config layer")
 	}
 
 	var origMetadata *AppImageMetadata
 	if origImage != nil {
 		origMetadata, err = e.GetMetadata(origImage)
 		if err != nil {
-			return nil, errors.Wrap(err, "find metadata")
+			return nil, nil, errors.Wrap(err, "find metadata")
 		}
 	}
 
 	for _, bpMetadata := range metadata.Buildpacks {
-		for layerName, data := range bpMetadata.Layers {
+		bpReport := BuildpackReport{ID: bpMetadata.ID, Version: bpMetadata.Version}
+		for _, layerName := range sortedLayerNames(bpMetadata.Layers) {
+			data := bpMetadata.Layers[layerName]
+			reused := false
 			tar := filepath.Join(e.ArtifactsDir, fmt.Sprintf("%s.tar", rawSHA(data.SHA)))
 			_, err := os.Stat(tar)
 			if os.IsNotExist(err) {
 				data.SHA, err = origLayerDiffID(origMetadata, bpMetadata.ID, layerName)
 				if err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 				hash, err := v1.NewHash(data.SHA)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "parse previous layer SHA %s/%s", bpMetadata.ID, layerName)
+				}
 				topLayer, err := origImage.LayerByDiffID(hash)
 				if err != nil {
-					return nil, errors.Wrapf(err, "find previous layer %s/%s", bpMetadata.ID, layerName)
+					return nil, nil, errors.Wrapf(err, "find previous layer %s/%s", bpMetadata.ID, layerName)
 				}
 				repoImage, err = mutate.AppendLayers(repoImage, topLayer)
 				if err != nil {
-					return nil, errors.Wrapf(err, "append layer %s/%s from previous image", bpMetadata.ID, layerName)
+					return nil, nil, errors.Wrapf(err, "append layer %s/%s from previous image", bpMetadata.ID, layerName)
 				}
 				bpMetadata.Layers[layerName] = data
+				reused = true
 			} else {
 				repoImage, _, err = img.Append(repoImage, tar)
 				if err != nil {
-					return nil, errors.Wrapf(err, "append new layer %s/%s", bpMetadata.ID, layerName)
+					return nil, nil, errors.Wrapf(err, "append new layer %s/%s", bpMetadata.ID, layerName)
 				}
 			}
+			bpReport.Layers = append(bpReport.Layers, LayerReport{Name: layerName, DiffID: data.SHA, Reused: reused})
 		}
+		report.Buildpacks = append(report.Buildpacks, bpReport)
 	}
 	metadataJSON, err := json.Marshal(metadata)
 	if err != nil {
-		return nil, errors.Wrap(err, "get encoded metadata")
+		return nil, nil, errors.Wrap(err, "get encoded metadata")
 	}
 	repoImage, err = img.Label(repoImage, MetadataLabel, string(metadataJSON))
 	if err != nil {
-		return nil, errors.Wrap(err, "set metadata label")
+		return nil, nil, errors.Wrap(err, "set metadata label")
 	}
 
 	repoImage, err = img.Env(repoImage, EnvLaunchDir, launchDirDst)
 	if err != nil {
-		return nil, errors.Wrap(err, "set launch dir env var")
+		return nil, nil, errors.Wrap(err, "set launch dir env var")
 	}
 
 	repoImage, err = img.Env(repoImage, EnvAppDir, appDirDst)
 	if err != nil {
-		return nil, errors.Wrap(err, "set app dir env var")
+		return nil, nil, errors.Wrap(err, "set app dir env var")
+	}
+
+	if e.SourceDate != nil {
+		repoImage, err = mutate.CreatedAt(repoImage, v1.Time{Time: *e.SourceDate})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "set image creation time")
+		}
+	}
+
+	digest, err := repoImage.Digest()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "find image digest")
+	}
+	imageID, err := repoImage.ConfigName()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "find image ID")
 	}
+	report.Image.Digest = digest.String()
+	report.Image.ImageID = imageID.String()
 
-	return repoImage, nil
+	return repoImage, report, nil
+}
+
+func sortedLayerNames(layers map[string]LayerMetadata) []string {
+	var names []string
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func origLayerDiffID(metadata *AppImageMetadata, buildpackID, layerName string) (string, error) {
@@ -227,10 +307,113 @@ func (e *Exporter) GetMetadata(image v1.Image) (*AppImageMetadata, error) {
 	return metadata, nil
 }
 
-func (e *Exporter) writeWithSHA(r io.Reader) (string, error) {
+func (e *Exporter) exportTar(sourceDir, destDir string) (string, error) {
+	if e.SourceDate != nil {
+		return writeReproducibleTar(e.ArtifactsDir, sourceDir, destDir, e.UID, e.GID, *e.SourceDate)
+	}
+	return writeTar(e.ArtifactsDir, sourceDir, destDir, e.UID, e.GID)
+}
+
+func writeTar(artifactsDir, sourceDir, destDir string, uid, gid int) (string, error) {
+	name := filepath.Base(sourceDir)
+	tarOptions := &archive.TarOptions{
+		IncludeFiles: []string{name},
+		RebaseNames: map[string]string{
+			name: destDir,
+		},
+	}
+	if uid > 0 && gid > 0 {
+		tarOptions.ChownOpts = &idtools.Identity{
+			UID: uid,
+			GID: gid,
+		}
+	}
+	rc, err := archive.TarWithOptions(filepath.Dir(sourceDir), tarOptions)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return writeWithSHA(artifactsDir, rc)
+}
+
+func writeReproducibleTar(artifactsDir, sourceDir, destDir string, uid, gid int, modTime time.Time) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(reproducibleTar(pw, sourceDir, destDir, uid, gid, modTime))
+	}()
+	defer pr.Close()
+	return writeWithSHA(artifactsDir, pr)
+}
+
+// reproducibleTar writes sourceDir to w as destDir. Entries are written in
+// lexical order (as walked), and only the name, type, permissions, size,
+// link target and device numbers of each file are preserved. GNU format is
+// used so that long names do not require host-specific PAX records.
+func reproducibleTar(w io.Writer, sourceDir, destDir string, uid, gid int, modTime time.Time) error {
+	if uid <= 0 || gid <= 0 {
+		uid, gid = 0, 0
+	}
+	modTime = modTime.Truncate(time.Second)
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(sourceDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(destDir, rel))
+		if fi.IsDir() {
+			name += "/"
+		}
+		header = &tar.Header{
+			Typeflag: header.Typeflag,
+			Name:     name,
+			Linkname: header.Linkname,
+			Size:     header.Size,
+			Mode:     header.Mode,
+			Uid:      uid,
+			Gid:      gid,
+			ModTime:  modTime,
+			Devmajor: header.Devmajor,
+			Devminor: header.Devminor,
+			Format:   tar.FormatGNU,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeWithSHA(artifactsDir string, r io.Reader) (string, error) {
 	hasher := sha256.New()
 
-	f, err := ioutil.TempFile(e.ArtifactsDir, "tarfile")
+	f, err := ioutil.TempFile(artifactsDir, "tarfile")
 	if err != nil {
 		return "", err
 	}
@@ -247,31 +430,9 @@ func (e *Exporter) writeWithSHA(r io.Reader) (string, error) {
 	if err := f.Close(); err != nil {
 		return "", err
 	}
-	if err := os.Rename(f.Name(), filepath.Join(e.ArtifactsDir, sha+".tar")); err != nil {
+	if err := os.Rename(f.Name(), filepath.Join(artifactsDir, sha+".tar")); err != nil {
 		return "", err
 	}
 
 	return "sha256:" + sha, nil
 }
-
-func (e *Exporter) exportTar(sourceDir, destDir string) (string, error) {
-	name := filepath.Base(sourceDir)
-	tarOptions := &archive.TarOptions{
-		IncludeFiles: []string{name},
-		RebaseNames: map[string]string{
-			name: destDir,
-		},
-	}
-	if e.UID > 0 && e.GID > 0 {
-		tarOptions.ChownOpts = &idtools.Identity{
-			UID: e.UID,
-			GID: e.GID,
-		}
-	}
-	rc, err := archive.TarWithOptions(filepath.Dir(sourceDir), tarOptions)
-	if err != nil {
-		return "", err
-	}
-	defer rc.Close()
-	return e.writeWithSHA(rc)
-}
@@ -19,6 +19,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -123,6 +124,168 @@ func testExporter(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#ExportImage", func() {
+		var runImage v1.Image
+
+		it.Before(func() {
+			var err error
+			runImage, err = random.Image(1024, 2)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+		})
+
+		it("should report the image and which layers were added or reused", func() {
+			assertNil(t, exporter.PrepareExport("testdata/exporter/first/launch", "/launch/dest", "testdata/exporter/first/launch/app", "/app/dest"))
+			firstImage, firstReport, err := exporter.ExportImage("/launch/dest", "/app/dest", runImage, nil)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			firstData, err := getMetadata(firstImage)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(firstReport.Buildpacks, []lifecycle.BuildpackReport{{
+				ID: "buildpack.id",
+				Layers: []lifecycle.LayerReport{
+					{Name: "layer1", DiffID: firstData.Buildpacks[0].Layers["layer1"].SHA},
+					{Name: "layer2", DiffID: firstData.Buildpacks[0].Layers["layer2"].SHA},
+				},
+			}}); s != "" {
+				t.Fatalf("Unexpected buildpacks:\n%s\n", s)
+			}
+
+			assertNil(t, exporter.PrepareExport("testdata/exporter/second/launch", "/launch/dest", "testdata/exporter/first/launch/app", "/app/dest"))
+			image, report, err := exporter.ExportImage("/launch/dest", "/app/dest", runImage, firstImage)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			data, err := getMetadata(image)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(report.Buildpacks[0].Layers, []lifecycle.LayerReport{
+				{Name: "layer1", DiffID: firstData.Buildpacks[0].Layers["layer1"].SHA, Reused: true},
+				{Name: "layer2", DiffID: data.Buildpacks[0].Layers["layer2"].SHA},
+			}); s != "" {
+				t.Fatalf("Unexpected layers:\n%s\n", s)
+			}
+
+			digest, err := image.Digest()
+			assertNil(t, err)
+			imageID, err := image.ConfigName()
+			assertNil(t, err)
+			runDigest, err := runImage.Digest()
+			assertNil(t, err)
+			runTopLayer, err := topLayer(runImage)
+			assertNil(t, err)
+			if s := cmp.Diff(report.Image, lifecycle.ImageReport{Digest: digest.String(), ImageID: imageID.String()}); s != "" {
+				t.Fatalf("Unexpected image:\n%s\n", s)
+			}
+			if s := cmp.Diff(report.RunImage, lifecycle.RunImageReport{Digest: runDigest.String(), TopLayer: runTopLayer.String()}); s != "" {
+				t.Fatalf("Unexpected run image:\n%s\n", s)
+			}
+		})
+	})
+
+	when("exporter has a source date set", func() {
+		var (
+			runImage   v1.Image
+			sourceDate time.Time
+			launchDir  string
+		)
+
+		it.Before(func() {
+			var err error
+			runImage, err = random.Image(1024, 2)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			sourceDate = time.Unix(1500000000, 0).UTC()
+			exporter.SourceDate = &sourceDate
+
+			launchDir = filepath.Join(tmpDir, "launch")
+			mkdir(t,
+				filepath.Join(launchDir, "app", "subdir"),
+				filepath.Join(launchDir, "config"),
+				filepath.Join(launchDir, "buildpack.id", "layer1"),
+				filepath.Join(launchDir, "buildpack.id", "layer2"),
+			)
+			mkfile(t, "app-file", filepath.Join(launchDir, "app", "subdir", "b"), filepath.Join(launchDir, "app", "a"))
+			mkfile(t, "[[processes]]", filepath.Join(launchDir, "config", "metadata.toml"))
+			mkfile(t, "layer-file", filepath.Join(launchDir, "buildpack.id", "layer1", "file"), filepath.Join(launchDir, "buildpack.id", "layer2", "file"))
+			mkfile(t, "", filepath.Join(launchDir, "buildpack.id", "layer1.toml"), filepath.Join(launchDir, "buildpack.id", "layer2.toml"))
+		})
+
+		export := func() v1.Image {
+			t.Helper()
+			artifactsDir, err := ioutil.TempDir(tmpDir, "artifacts")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			exporter.ArtifactsDir = artifactsDir
+			image, err := exporter.Export(launchDir, "/launch/dest", filepath.Join(launchDir, "app"), "/app/dest", runImage, nil)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			return image
+		}
+
+		it("should produce the same image digest for the same inputs", func() {
+			firstImage := export()
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(launchDir, "app", "a"), later, later); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if err := os.Chtimes(filepath.Join(launchDir, "buildpack.id", "layer2"), later, later); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			image := export()
+
+			firstDigest, err := firstImage.Digest()
+			assertNil(t, err)
+			digest, err := image.Digest()
+			assertNil(t, err)
+			if s := cmp.Diff(digest, firstDigest); s != "" {
+				t.Fatalf("Unexpected digest:\n%s\n", s)
+			}
+		})
+
+		it("should normalize tar headers and set the image creation time", func() {
+			image := export()
+			config, err := image.ConfigFile()
+			assertNil(t, err)
+			if !config.Created.Time.Equal(sourceDate) {
+				t.Fatalf("Unexpected creation time: %s\n", config.Created.Time)
+			}
+
+			layers, err := image.Layers()
+			assertNil(t, err)
+			r, err := layers[len(layers)-1].Uncompressed()
+			assertNil(t, err)
+			defer r.Close()
+			tr := tar.NewReader(r)
+			var names []string
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				assertNil(t, err)
+				names = append(names, header.Name)
+				if !header.ModTime.Equal(sourceDate) || header.Uid != 0 || header.Gid != 0 || header.Uname != "" || len(header.PAXRecords) != 0 {
+					t.Fatalf("Unexpected header: %+v\n", header)
+				}
+			}
+			if s := cmp.Diff(names, []string{
+				"/launch/dest/buildpack.id/layer2/",
+				"/launch/dest/buildpack.id/layer2/file",
+			}); s != "" {
+				t.Fatalf("Unexpected entries:\n%s\n", s)
+			}
+		})
+	})
+
 	when("#Export", func() {
 		var runImage v1.Image
 
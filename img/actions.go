@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
@@ -44,6 +45,23 @@ func Rebase(orig v1.Image, newBase v1.Image, oldBaseFinder ImageFinder) (v1.Imag
 	return image, nil
 }
 
+func Base(image v1.Image, topLayer v1.Hash) (v1.Image, error) {
+	layers, err := image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for i, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		if diffID == topLayer {
+			return mutate.AppendLayers(empty.Image, layers[:i+1]...)
+		}
+	}
+	return nil, fmt.Errorf("image has no layer with diff ID '%s'", topLayer)
+}
+
 func TopLayerDiffID(image v1.Image) (v1.Hash, error) {
 	layers, err := image.Layers()
 	if err != nil {
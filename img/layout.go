@@ -0,0 +1,289 @@
+package img
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	layoutIndex      = "index.json"
+	layoutFile       = "oci-layout"
+	layoutVersion    = `{"imageLayoutVersion": "1.0.0"}`
+	layoutAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// NewLayout returns a Store for the image recorded under tag in the
+// OCI image layout at path. Several images may share one layout.
+func NewLayout(path, tag string) (Store, error) {
+	r, err := name.ParseReference(tag, name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	return &layoutStore{path: path, tag: tag, ref: r}, nil
+}
+
+// layoutLocks serializes index updates for stores that share a layout.
+var layoutLocks sync.Map
+
+type layoutStore struct {
+	path string
+	tag  string
+	ref  name.Reference
+}
+
+func (l *layoutStore) Ref() name.Reference {
+	return l.ref
+}
+
+// NotFoundError is returned by a layout Store when it has no image for the tag,
+// including when the layout does not exist yet.
+type NotFoundError struct {
+	Tag, Path string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("image '%s' not found in layout '%s'", e.Tag, e.Path)
+}
+
+// LayoutError is returned by a layout Store when the layout cannot be read.
+type LayoutError struct {
+	Path string
+	Err  error
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("read OCI image layout '%s': %s", e.Path, e.Err)
+}
+
+func (l *layoutStore) Image() (v1.Image, error) {
+	index, err := l.readIndex()
+	if os.IsNotExist(err) {
+		return nil, &NotFoundError{Tag: l.tag, Path: l.path}
+	} else if err != nil {
+		return nil, &LayoutError{Path: l.path, Err: err}
+	}
+	for _, desc := range index.Manifests {
+		if desc.Annotations[layoutAnnotation] == l.tag {
+			return partial.CompressedToImage(&layoutImage{store: l, desc: desc})
+		}
+	}
+	return nil, &NotFoundError{Tag: l.tag, Path: l.path}
+}
+
+func (l *layoutStore) Write(image v1.Image) error {
+	if err := os.MkdirAll(filepath.Join(l.path, "blobs", "sha256"), 0777); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(l.path, layoutFile), []byte(layoutVersion), 0666); err != nil {
+		return err
+	}
+
+	layers, err := image.Layers()
+	if err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return err
+		}
+		if err := l.writeBlob(digest, layer.Compressed); err != nil {
+			return err
+		}
+	}
+
+	config, err := image.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	configName, err := image.ConfigName()
+	if err != nil {
+		return err
+	}
+	if err := l.writeBlob(configName, bytesReader(config)); err != nil {
+		return err
+	}
+
+	manifest, err := image.RawManifest()
+	if err != nil {
+		return err
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return err
+	}
+	mediaType, err := image.MediaType()
+	if err != nil {
+		return err
+	}
+	if err := l.writeBlob(digest, bytesReader(manifest)); err != nil {
+		return err
+	}
+
+	lock, _ := layoutLocks.LoadOrStore(l.lockKey(), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	index, err := l.readIndex()
+	if os.IsNotExist(err) {
+		index = &v1.IndexManifest{SchemaVersion: 2}
+	} else if err != nil {
+		return err
+	}
+	manifests := index.Manifests[:0]
+	for _, desc := range index.Manifests {
+		if desc.Annotations[layoutAnnotation] != l.tag {
+			manifests = append(manifests, desc)
+		}
+	}
+	index.Manifests = append(manifests, v1.Descriptor{
+		MediaType:   mediaType,
+		Size:        int64(len(manifest)),
+		Digest:      digest,
+		Annotations: map[string]string{layoutAnnotation: l.tag},
+	})
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return l.writeFile(filepath.Join(l.path, layoutIndex), bytesReader(indexJSON))
+}
+
+func (l *layoutStore) lockKey() string {
+	if path, err := filepath.Abs(l.path); err == nil {
+		return path
+	}
+	return filepath.Clean(l.path)
+}
+
+func (l *layoutStore) readIndex() (*v1.IndexManifest, error) {
+	f, err := os.Open(filepath.Join(l.path, layoutIndex))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return v1.ParseIndexManifest(f)
+}
+
+func (l *layoutStore) blobPath(h v1.Hash) string {
+	return filepath.Join(l.path, "blobs", h.Algorithm, h.Hex)
+}
+
+// writeBlob skips blobs that are already present, so that layers shared
+// between images in the layout (e.g., run image layers) are stored once.
+func (l *layoutStore) writeBlob(h v1.Hash, open func() (io.ReadCloser, error)) error {
+	path := l.blobPath(h)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return l.writeFile(path, open)
+}
+
+func (l *layoutStore) writeFile(path string, open func() (io.ReadCloser, error)) error {
+	in, err := open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, in); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func bytesReader(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+type layoutImage struct {
+	store    *layoutStore
+	desc     v1.Descriptor
+	manifest *v1.Manifest
+}
+
+func (i *layoutImage) MediaType() (types.MediaType, error) {
+	return i.desc.MediaType, nil
+}
+
+func (i *layoutImage) RawManifest() ([]byte, error) {
+	return ioutil.ReadFile(i.store.blobPath(i.desc.Digest))
+}
+
+func (i *layoutImage) RawConfigFile() ([]byte, error) {
+	manifest, err := i.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(i.store.blobPath(manifest.Config.Digest))
+}
+
+func (i *layoutImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	manifest, err := i.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	if h == manifest.Config.Digest {
+		return &layoutLayer{store: i.store, desc: manifest.Config}, nil
+	}
+	for _, desc := range manifest.Layers {
+		if h == desc.Digest {
+			return &layoutLayer{store: i.store, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("blob '%s' not found in manifest", h)
+}
+
+func (i *layoutImage) readManifest() (*v1.Manifest, error) {
+	if i.manifest != nil {
+		return i.manifest, nil
+	}
+	b, err := i.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	i.manifest = manifest
+	return manifest, nil
+}
+
+type layoutLayer struct {
+	store *layoutStore
+	desc  v1.Descriptor
+}
+
+func (l *layoutLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *layoutLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.store.blobPath(l.desc.Digest))
+}
+
+func (l *layoutLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
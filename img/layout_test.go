@@ -0,0 +1,197 @@
+package img_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle/img"
+)
+
+func TestLayout(t *testing.T) {
+	spec.Run(t, "Layout", testLayout, spec.Report(report.Terminal{}))
+}
+
+func testLayout(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir string
+		image  v1.Image
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.img.layout")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		image, err = random.Image(1024, 2)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	newLayout := func(tag string) img.Store {
+		t.Helper()
+		store, err := img.NewLayout(tmpDir, tag)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		return store
+	}
+
+	when("#Image", func() {
+		it("should return a not found error when the layout has no index", func() {
+			_, err := newLayout("some/image:latest").Image()
+			if _, ok := err.(*img.NotFoundError); !ok {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
+
+		it("should return a not found error when the tag is not in the layout", func() {
+			if err := newLayout("some/other-image:latest").Write(image); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			_, err := newLayout("some/image:latest").Image()
+			if _, ok := err.(*img.NotFoundError); !ok {
+				t.Fatalf("Incorrect error: %s\n", err)
+			}
+		})
+
+		it("should return a layout error when the index is corrupt", func() {
+			if err := ioutil.WriteFile(filepath.Join(tmpDir, "index.json"), []byte("{"), 0666); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			_, err := newLayout("some/image:latest").Image()
+			if err, ok := err.(*img.LayoutError); !ok {
+				t.Fatalf("Incorrect error: %s\n", err)
+			} else if !strings.Contains(err.Error(), "read OCI image layout") {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+		})
+
+		it("should read back an image that was written", func() {
+			if err := newLayout("some/image:latest").Write(image); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			readImage, err := newLayout("some/image:latest").Image()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(digest(t, readImage), digest(t, image)); s != "" {
+				t.Fatalf("Unexpected digest:\n%s\n", s)
+			}
+			if s := cmp.Diff(diffIDs(t, readImage), diffIDs(t, image)); s != "" {
+				t.Fatalf("Unexpected diffIDs:\n%s\n", s)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "oci-layout")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+		})
+	})
+
+	when("#Write", func() {
+		it("should keep images with other tags and share their blobs", func() {
+			if err := newLayout("some/run-image:latest").Write(image); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			runImage, err := newLayout("some/run-image:latest").Image()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			appLayers, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			appLayer, err := appLayers.Layers()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			appImage, err := mutate.AppendLayers(runImage, appLayer...)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if err := newLayout("some/app-image:latest").Write(appImage); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+
+			if readImage, err := newLayout("some/run-image:latest").Image(); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			} else if s := cmp.Diff(digest(t, readImage), digest(t, image)); s != "" {
+				t.Fatalf("Unexpected run image digest:\n%s\n", s)
+			}
+			if readImage, err := newLayout("some/app-image:latest").Image(); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			} else if s := cmp.Diff(digest(t, readImage), digest(t, appImage)); s != "" {
+				t.Fatalf("Unexpected app image digest:\n%s\n", s)
+			}
+
+			// 3 layers, 2 configs and 2 manifests
+			blobs, err := ioutil.ReadDir(filepath.Join(tmpDir, "blobs", "sha256"))
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(len(blobs), 7); s != "" {
+				t.Fatalf("Unexpected blobs:\n%s\n", s)
+			}
+		})
+
+		it("should replace the image for an existing tag", func() {
+			if err := newLayout("some/image:latest").Write(image); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			newImage, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if err := newLayout("some/image:latest").Write(newImage); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			readImage, err := newLayout("some/image:latest").Image()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(digest(t, readImage), digest(t, newImage)); s != "" {
+				t.Fatalf("Unexpected digest:\n%s\n", s)
+			}
+		})
+	})
+}
+
+func digest(t *testing.T, image v1.Image) string {
+	t.Helper()
+	h, err := image.Digest()
+	if err != nil {
+		t.Fatalf("Error: %s\n", err)
+	}
+	return h.String()
+}
+
+func diffIDs(t *testing.T, image v1.Image) []string {
+	t.Helper()
+	layers, err := image.Layers()
+	if err != nil {
+		t.Fatalf("Error: %s\n", err)
+	}
+	var ids []string
+	for _, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		ids = append(ids, diffID.String())
+	}
+	return ids
+}
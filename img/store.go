@@ -1,13 +1,18 @@
 package img
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/daemon"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
 
 type Store interface {
@@ -69,3 +74,56 @@ func (d *daemonStore) Write(image v1.Image) error {
 	_, err := daemon.Write(d.tag, image, daemon.WriteOptions{})
 	return err
 }
+
+// NewTarball returns a Store for a docker-archive (`docker save`
+// compatible) tarball at path containing the image tagged with tag.
+func NewTarball(path, tag string) (Store, error) {
+	t, err := name.NewTag(tag, name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+	return &tarballStore{path: path, tag: t}, nil
+}
+
+type tarballStore struct {
+	path string
+	tag  name.Tag
+}
+
+func (t *tarballStore) Ref() name.Reference {
+	return t.tag
+}
+
+func (t *tarballStore) Image() (v1.Image, error) {
+	return tarball.ImageFromPath(t.path, &t.tag)
+}
+
+func (t *tarballStore) Write(image v1.Image) error {
+	return tarball.WriteToFile(t.path, t.tag, image, &tarball.WriteOptions{})
+}
+
+// WriteAll writes image to each of stores concurrently. All writes are
+// attempted, and any failures are combined into the returned error.
+func WriteAll(image v1.Image, stores ...Store) error {
+	errs := make([]error, len(stores))
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store Store) {
+			defer wg.Done()
+			errs[i] = store.Write(image)
+		}(i, store)
+	}
+	wg.Wait()
+
+	var messages []string
+	for i, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %s", stores[i].Ref(), err))
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package img_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle/img"
+)
+
+func TestWriteAll(t *testing.T) {
+	spec.Run(t, "WriteAll", testWriteAll, spec.Report(report.Terminal{}))
+}
+
+func testWriteAll(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.img.write-all")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	it("should write the image to every store", func() {
+		image, err := random.Image(1024, 2)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		var stores []img.Store
+		for _, tag := range []string{"some/image:tag1", "some/image:tag2", "other/image:tag3"} {
+			store, err := img.NewLayout(tmpDir, tag)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			stores = append(stores, store)
+		}
+		tarStore, err := img.NewTarball(filepath.Join(tmpDir, "image.tar"), "some/image:tag4")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		stores = append(stores, tarStore)
+
+		if err := img.WriteAll(image, stores...); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		for _, store := range stores {
+			readImage, err := store.Image()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(diffIDs(t, readImage), diffIDs(t, image)); s != "" {
+				t.Fatalf("Unexpected diffIDs for %s:\n%s\n", store.Ref(), s)
+			}
+		}
+	})
+
+	it("should return an error naming each store that failed", func() {
+		image, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		goodStore, err := img.NewLayout(tmpDir, "some/image:good")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		badStore, err := img.NewTarball(filepath.Join(tmpDir, "missing", "image.tar"), "some/image:bad")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+
+		err = img.WriteAll(image, goodStore, badStore)
+		if err == nil {
+			t.Fatal("Expected error.\n")
+		}
+		if !strings.Contains(err.Error(), "some/image:bad") || strings.Contains(err.Error(), "some/image:good") {
+			t.Fatalf("Unexpected error: %s\n", err)
+		}
+		if _, err := goodStore.Image(); err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	})
+}
+
+func TestTarball(t *testing.T) {
+	spec.Run(t, "Tarball", testTarball, spec.Report(report.Terminal{}))
+}
+
+func testTarball(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.img.tarball")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#Write", func() {
+		it("should write a tarball that can be read back", func() {
+			image, err := random.Image(1024, 2)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			store, err := img.NewTarball(filepath.Join(tmpDir, "image.tar"), "some/image:some-tag")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if err := store.Write(image); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+
+			readImage, err := store.Image()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(diffIDs(t, readImage), diffIDs(t, image)); s != "" {
+				t.Fatalf("Unexpected diffIDs:\n%s\n", s)
+			}
+			readConfig, err := readImage.ConfigName()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			config, err := image.ConfigName()
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(readConfig, config); s != "" {
+				t.Fatalf("Unexpected config:\n%s\n", s)
+			}
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -19,41 +20,42 @@ type Launcher struct {
 	Exec               func(argv0 string, argv []string, envv []string) error
 }
 
-func (l *Launcher) Launch(executable, startCommand string) error {
+// Launch runs the process type named by the first of args, the default
+// process type if args is empty, or otherwise args joined with spaces as a
+// bash command. Direct processes are executed without a shell, so profile.d
+// scripts are not applied to them, and receive the rest of args after their
+// own args.
+func (l *Launcher) Launch(executable string, args []string) error {
 	env := &Env{
 		Getenv:  os.Getenv,
 		Setenv:  os.Setenv,
 		Environ: os.Environ,
 		Map:     POSIXLaunchEnv,
 	}
-	appInfo, err := os.Stat(l.AppDir)
-	if err != nil {
-		return errors.Wrap(err, "find app directory")
-	}
-	if err := l.eachDir(l.LaunchDir, func(bp string) error {
-		bpPath := filepath.Join(l.LaunchDir, bp)
-		bpInfo, err := os.Stat(bpPath)
-		if err != nil {
-			return errors.Wrap(err, "find buildpack directory")
-		}
-		if os.SameFile(appInfo, bpInfo) {
-			return nil
-		}
-		return l.eachDir(bpPath, func(layer string) error {
-			return env.AddRootDir(filepath.Join(bpPath, layer))
-		})
-	}); err != nil {
+	if err := l.setupEnv(env); err != nil {
 		return errors.Wrap(err, "modify env")
 	}
 	if err := os.Chdir(l.AppDir); err != nil {
 		return errors.Wrap(err, "change to app directory")
 	}
 
-	startCommand, err = l.processFor(startCommand)
+	process, err := l.processFor(args)
 	if err != nil {
 		return errors.Wrap(err, "determine start command")
 	}
 
+	if process.Direct {
+		binary, err := exec.LookPath(process.Command)
+		if err != nil {
+			return errors.Wrap(err, "find start command")
+		}
+		argv := append([]string{process.Command}, process.Args...)
+		if err := l.Exec(binary, argv, os.Environ()); err != nil {
+			return errors.Wrap(err, "exec")
+		}
+		return nil
+	}
+
 	launcher, err := l.profileD()
 	if err != nil {
 		return errors.Wrap(err, "determine profile")
@@ -62,13 +64,75 @@ func (l *Launcher) Launch(executable, startCommand string) error {
 	if err := l.Exec("/bin/bash", []string{
 		"bash", "-c",
 		launcher, executable,
-		startCommand,
+		process.Command,
 	}, os.Environ()); err != nil {
 		return errors.Wrap(err, "exec")
 	}
 	return nil
 }
 
+// setupEnv adds the root and env directories of each buildpack's layers
+// to env, in buildpack order. Profile scripts are applied afterwards.
+func (l *Launcher) setupEnv(env *Env) error {
+	bps, err := l.buildpackDirs()
+	if err != nil {
+		return err
+	}
+	for _, bp := range bps {
+		bpPath := filepath.Join(l.LaunchDir, bp)
+		if err := l.eachDir(bpPath, func(layer string) error {
+			return env.AddRootDir(filepath.Join(bpPath, layer))
+		}); err != nil {
+			return err
+		}
+		if err := l.eachDir(bpPath, func(layer string) error {
+			if err := env.AddEnvDir(filepath.Join(bpPath, layer, "env")); err != nil {
+				return err
+			}
+			return env.AddEnvDir(filepath.Join(bpPath, layer, "env.launch"))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildpackDirs returns the directories in the launch directory, with the
+// buildpacks in l.Buildpacks first and in that order, and the rest in
+// directory order. The app directory is skipped if it is in the launch
+// directory.
+func (l *Launcher) buildpackDirs() ([]string, error) {
+	appInfo, err := os.Stat(l.AppDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "find app directory")
+	}
+	var dirs []string
+	add := func(bp string) error {
+		if containsString(dirs, bp) {
+			return nil
+		}
+		bpInfo, err := os.Stat(filepath.Join(l.LaunchDir, bp))
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "find buildpack directory")
+		}
+		if !os.SameFile(appInfo, bpInfo) {
+			dirs = append(dirs, bp)
+		}
+		return nil
+	}
+	for _, bp := range l.Buildpacks {
+		if err := add(bp); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.eachDir(l.LaunchDir, add); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
 func (l *Launcher) profileD() (string, error) {
 	var out []string
 
@@ -106,30 +170,36 @@ func (l *Launcher) profileD() (string, error) {
 	return strings.Join(out, "\n"), nil
 }
 
-func (l *Launcher) processFor(cmd string) (string, error) {
-	if cmd == "" {
+func (l *Launcher) processFor(args []string) (Process, error) {
+	if len(args) == 0 {
 		if process, ok := l.findProcessType(l.DefaultProcessType); ok {
 			return process, nil
 		}
 
-		return "", fmt.Errorf("process type %s was not found", l.DefaultProcessType)
+		return Process{}, fmt.Errorf("process type %s was not found", l.DefaultProcessType)
 	}
 
-	if process, ok := l.findProcessType(cmd); ok {
-		return process, nil
+	if process, ok := l.findProcessType(args[0]); ok {
+		if process.Direct {
+			process.Args = append(append([]string(nil), process.Args...), args[1:]...)
+			return process, nil
+		}
+		if len(args) == 1 {
+			return process, nil
+		}
 	}
 
-	return cmd, nil
+	return Process{Command: strings.Join(args, " ")}, nil
 }
 
-func (l *Launcher) findProcessType(kind string) (string, bool) {
+func (l *Launcher) findProcessType(kind string) (Process, bool) {
 	for _, p := range l.Processes {
 		if p.Type == kind {
-			return p.Command, true
+			return p, true
 		}
 	}
 
-	return "", false
+	return Process{}, false
 }
 
 func (*Launcher) eachDir(dir string, fn func(file string) error) error {
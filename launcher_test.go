@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 
@@ -29,6 +30,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 		launcher            *lifecycle.Launcher
 		tmpDir              string
 		syscallExecArgsColl []syscallExecArgs
+		path                string
 	)
 
 	it.Before(func() {
@@ -69,7 +71,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 	when("#Launch", func() {
 		when("no start command has been specified", func() {
 			it("should run the default process type", func() {
-				if err := launcher.Launch("/path/to/launcher", ""); err != nil {
+				if err := launcher.Launch("/path/to/launcher", nil); err != nil {
 					t.Fatal(err)
 				}
 
@@ -93,7 +95,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 				it("should return an error", func() {
 					launcher.DefaultProcessType = "not-exist"
 
-					err := launcher.Launch("/path/to/launcher", "")
+					err := launcher.Launch("/path/to/launcher", nil)
 					if err == nil {
 						t.Fatalf("expected launch to return an error")
 					}
@@ -108,7 +110,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 		when("start command has been specified", func() {
 			when("start command matches a process type", func() {
 				it("should run that process type", func() {
-					if err := launcher.Launch("/path/to/launcher", "worker"); err != nil {
+					if err := launcher.Launch("/path/to/launcher", []string{"worker"}); err != nil {
 						t.Fatal(err)
 					}
 
@@ -124,7 +126,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 
 			when("start command does NOT match a process type", func() {
 				it("should run the start command", func() {
-					if err := launcher.Launch("/path/to/launcher", "some-different-process"); err != nil {
+					if err := launcher.Launch("/path/to/launcher", []string{"some-different-process"}); err != nil {
 						t.Fatal(err)
 					}
 
@@ -139,6 +141,290 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("the process is direct", func() {
+			it.Before(func() {
+				mkdir(t, filepath.Join(tmpDir, "launch", "bp.1", "layer", "bin"))
+				mkfile(t, "#!/bin/sh\necho \"$#: $1\"\n", filepath.Join(tmpDir, "launch", "bp.1", "layer", "bin", "some-binary"))
+				mkdir(t, filepath.Join(tmpDir, "launch", "bp.1", "layer", "profile.d"))
+				mkfile(t, "echo from profile.d", filepath.Join(tmpDir, "launch", "bp.1", "layer", "profile.d", "script"))
+				launcher.Processes = []lifecycle.Process{
+					{Type: "direct", Command: "some-binary", Args: []string{"some arg", "other-arg"}, Direct: true},
+				}
+				launcher.Buildpacks = []string{"bp.1"}
+				path = os.Getenv("PATH")
+			})
+
+			it.After(func() {
+				os.Setenv("PATH", path)
+			})
+
+			it("should exec the command found on the launch PATH with its args", func() {
+				if err := launcher.Launch("/path/to/launcher", []string{"direct"}); err != nil {
+					t.Fatal(err)
+				}
+
+				if diff := cmp.Diff(syscallExecArgsColl, []syscallExecArgs{{
+					argv0: filepath.Join(tmpDir, "launch", "bp.1", "layer", "bin", "some-binary"),
+					argv:  []string{"some-binary", "some arg", "other-arg"},
+					envv:  os.Environ(),
+				}}, cmp.AllowUnexported(syscallExecArgs{})); diff != "" {
+					t.Fatalf(`syscall.Exec did not match: (-got +want)\n%s`, diff)
+				}
+			})
+
+			it("should run without a shell or profile.d scripts", func() {
+				launcher.Exec = syscallExecWithStdout(t, tmpDir)
+				if err := launcher.Launch("/path/to/launcher", []string{"direct"}); err != nil {
+					t.Fatal(err)
+				}
+
+				stdout, err := ioutil.ReadFile(filepath.Join(tmpDir, "stdout"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(string(stdout), "2: some arg\n"); diff != "" {
+					t.Fatalf(`syscall.Exec stdout did not match: (-got +want)\n%s`, diff)
+				}
+			})
+
+			it("should append extra args to the process args", func() {
+				if err := launcher.Launch("/path/to/launcher", []string{"direct", "extra arg"}); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(syscallExecArgsColl) != 1 {
+					t.Fatalf("expected syscall.Exec to be called once: actual %v", syscallExecArgsColl)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv, []string{"some-binary", "some arg", "other-arg", "extra arg"}); diff != "" {
+					t.Fatalf(`syscall.Exec Argv did not match: (-got +want)\n%s`, diff)
+				}
+				if diff := cmp.Diff(launcher.Processes[0].Args, []string{"some arg", "other-arg"}); diff != "" {
+					t.Fatalf(`Process args were modified: (-got +want)\n%s`, diff)
+				}
+			})
+
+			it("should return an error when the command is not found", func() {
+				launcher.Processes[0].Command = "missing-binary"
+				if err := launcher.Launch("/path/to/launcher", []string{"direct"}); err == nil {
+					t.Fatal("expected launch to return an error")
+				}
+				if len(syscallExecArgsColl) != 0 {
+					t.Fatalf("expected syscall.Exec to not be called: actual %v", syscallExecArgsColl)
+				}
+			})
+		})
+
+		when("the start command has args", func() {
+			it.Before(func() {
+				mkfile(t, "#!/usr/bin/env bash\necho \"$#: $1\"\n", filepath.Join(tmpDir, "launch", "app", "start"))
+			})
+
+			it("should join the args into one shell command", func() {
+				if err := launcher.Launch("/path/to/launcher", []string{"./start", "some-arg", "other-arg"}); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(syscallExecArgsColl) != 1 {
+					t.Fatalf("expected syscall.Exec to be called once: actual %v", syscallExecArgsColl)
+				}
+				if diff := cmp.Diff(syscallExecArgsColl[0].argv[3:], []string{"/path/to/launcher", "./start some-arg other-arg"}); diff != "" {
+					t.Fatalf(`syscall.Exec Argv did not match: (-got +want)\n%s`, diff)
+				}
+			})
+
+			it("should run the command with bash after profile.d scripts", func() {
+				mkdir(t, filepath.Join(tmpDir, "launch", "bp.1", "layer", "profile.d"))
+				mkfile(t, "echo from profile.d", filepath.Join(tmpDir, "launch", "bp.1", "layer", "profile.d", "script"))
+				launcher.Buildpacks = []string{"bp.1"}
+				launcher.Exec = syscallExecWithStdout(t, tmpDir)
+				if err := launcher.Launch("/path/to/launcher", []string{"./start", "some-arg", "other-arg"}); err != nil {
+					t.Fatal(err)
+				}
+
+				stdout, err := ioutil.ReadFile(filepath.Join(tmpDir, "stdout"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(string(stdout), "from profile.d\n2: some-arg\n"); diff != "" {
+					t.Fatalf(`syscall.Exec stdout did not match: (-got +want)\n%s`, diff)
+				}
+			})
+		})
+
+		when("the start command uses shell syntax", func() {
+			it("should run it with bash", func() {
+				launcher.Exec = syscallExecWithStdout(t, tmpDir)
+				if err := launcher.Launch("/path/to/launcher", []string{`echo one && X=two; echo "$X" | cat`}); err != nil {
+					t.Fatal(err)
+				}
+
+				stdout, err := ioutil.ReadFile(filepath.Join(tmpDir, "stdout"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(string(stdout), "one\ntwo\n"); diff != "" {
+					t.Fatalf(`syscall.Exec stdout did not match: (-got +want)\n%s`, diff)
+				}
+			})
+		})
+
+		when("buildpacks provided launch env files", func() {
+			it.Before(func() {
+				layerDir := filepath.Join(tmpDir, "launch", "bp.1", "layer")
+				mkdir(t, filepath.Join(layerDir, "env.launch"), filepath.Join(layerDir, "env.build"))
+				mkfile(t, "-Xmx1g", filepath.Join(layerDir, "env.launch", "LIFECYCLE_TEST_OPTS.append"))
+				mkfile(t, " ", filepath.Join(layerDir, "env.launch", "LIFECYCLE_TEST_OPTS.delim"))
+				mkfile(t, "some-build-value", filepath.Join(layerDir, "env.build", "LIFECYCLE_TEST_BUILD"))
+				os.Setenv("LIFECYCLE_TEST_OPTS", "-Xss1m")
+				launcher.Buildpacks = []string{"bp.1"}
+			})
+
+			it.After(func() {
+				os.Unsetenv("LIFECYCLE_TEST_OPTS")
+				os.Unsetenv("LIFECYCLE_TEST_BUILD")
+			})
+
+			it("should apply env.launch but not env.build", func() {
+				if err := launcher.Launch("/path/to/launcher", nil); err != nil {
+					t.Fatal(err)
+				}
+				if len(syscallExecArgsColl) != 1 {
+					t.Fatalf("expected syscall.Exec to be called once: actual %v", syscallExecArgsColl)
+				}
+				var opts, build []string
+				for _, kv := range syscallExecArgsColl[0].envv {
+					if strings.HasPrefix(kv, "LIFECYCLE_TEST_OPTS=") {
+						opts = append(opts, kv)
+					}
+					if strings.HasPrefix(kv, "LIFECYCLE_TEST_BUILD=") {
+						build = append(build, kv)
+					}
+				}
+				if diff := cmp.Diff(opts, []string{"LIFECYCLE_TEST_OPTS=-Xss1m -Xmx1g"}); diff != "" {
+					t.Fatalf(`syscall.Exec env did not match: (-got +want)\n%s`, diff)
+				}
+				if len(build) != 0 {
+					t.Fatalf("expected build env to be omitted: actual %v", build)
+				}
+			})
+		})
+
+		when("buildpack directories are not listed in the buildpacks", func() {
+			it.Before(func() {
+				mkdir(t,
+					filepath.Join(tmpDir, "launch", "bp.1", "layer", "env"),
+					filepath.Join(tmpDir, "launch", "bp.2", "layer", "env"),
+					filepath.Join(tmpDir, "launch", "app", "layer", "env"),
+				)
+				mkfile(t, "apple", filepath.Join(tmpDir, "launch", "bp.1", "layer", "env", "LIFECYCLE_TEST_VAR.append"))
+				mkfile(t, "banana", filepath.Join(tmpDir, "launch", "bp.2", "layer", "env", "LIFECYCLE_TEST_VAR.append"))
+				mkfile(t, "cherry", filepath.Join(tmpDir, "launch", "app", "layer", "env", "LIFECYCLE_TEST_VAR.append"))
+				launcher.Buildpacks = []string{"bp.2"}
+			})
+
+			it.After(func() {
+				os.Unsetenv("LIFECYCLE_TEST_VAR")
+			})
+
+			it("should apply them after the listed buildpacks and skip the app directory", func() {
+				if err := launcher.Launch("/path/to/launcher", nil); err != nil {
+					t.Fatal(err)
+				}
+				if len(syscallExecArgsColl) != 1 {
+					t.Fatalf("expected syscall.Exec to be called once: actual %v", syscallExecArgsColl)
+				}
+				var vars []string
+				for _, kv := range syscallExecArgsColl[0].envv {
+					if strings.HasPrefix(kv, "LIFECYCLE_TEST_VAR=") {
+						vars = append(vars, kv)
+					}
+				}
+				if diff := cmp.Diff(vars, []string{"LIFECYCLE_TEST_VAR=bananaapple"}); diff != "" {
+					t.Fatalf(`syscall.Exec env did not match: (-got +want)\n%s`, diff)
+				}
+			})
+		})
+
+		when("the app directory does not exist", func() {
+			it("should return an error", func() {
+				launcher.AppDir = filepath.Join(tmpDir, "missing-app")
+				err := launcher.Launch("/path/to/launcher", nil)
+				if err == nil || !strings.Contains(err.Error(), "find app directory") {
+					t.Fatalf("Unexpected error: %v\n", err)
+				}
+				if len(syscallExecArgsColl) != 0 {
+					t.Fatalf("expected syscall.Exec not to be called: actual %v", syscallExecArgsColl)
+				}
+			})
+		})
+
+		when("buildpacks provided layer env files", func() {
+			it.Before(func() {
+				mkfile(t, "#!/usr/bin/env bash\necho \"$LIFECYCLE_TEST_VAR\"\n", filepath.Join(tmpDir, "launch", "app", "start"))
+				launcher.Processes = []lifecycle.Process{
+					{Type: "start", Command: "./start"},
+				}
+				launcher.Buildpacks = []string{"bp.1", "bp.2"}
+				launcher.Exec = syscallExecWithStdout(t, tmpDir)
+
+				mkdir(t,
+					filepath.Join(tmpDir, "launch", "bp.1", "layer", "env"),
+					filepath.Join(tmpDir, "launch", "bp.2", "layer", "env"),
+					filepath.Join(tmpDir, "launch", "bp.2", "layer", "profile.d"),
+				)
+				mkfile(t, "apple", filepath.Join(tmpDir, "launch", "bp.1", "layer", "env", "LIFECYCLE_TEST_VAR.override"))
+				mkfile(t, "banana", filepath.Join(tmpDir, "launch", "bp.2", "layer", "env", "LIFECYCLE_TEST_VAR.append"))
+				mkfile(t, " ", filepath.Join(tmpDir, "launch", "bp.2", "layer", "env", "LIFECYCLE_TEST_VAR.delim"))
+				mkfile(t, "echo \"$LIFECYCLE_TEST_VAR\"\nexport LIFECYCLE_TEST_VAR=cherry", filepath.Join(tmpDir, "launch", "bp.2", "layer", "profile.d", "cherry"))
+			})
+
+			it.After(func() {
+				os.Unsetenv("LIFECYCLE_TEST_VAR")
+			})
+
+			it("should apply them in buildpack order before profile.d scripts", func() {
+				if err := launcher.Launch("/path/to/launcher", []string{"start"}); err != nil {
+					t.Fatal(err)
+				}
+
+				stdout, err := ioutil.ReadFile(filepath.Join(tmpDir, "stdout"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(stdout) == 0 {
+					stderr, _ := ioutil.ReadFile(filepath.Join(tmpDir, "stderr"))
+					t.Fatalf("stdout was empty: stderr: %s", stderr)
+				}
+				if diff := cmp.Diff(string(stdout), "apple banana\ncherry\n"); diff != "" {
+					t.Fatalf(`syscall.Exec stdout did not match: (-got +want)\n%s`, diff)
+				}
+			})
+
+			when("changing the buildpack order", func() {
+				it.Before(func() {
+					launcher.Buildpacks = []string{"bp.2", "bp.1"}
+				})
+
+				it("should apply them in buildpack order", func() {
+					if err := launcher.Launch("/path/to/launcher", []string{"start"}); err != nil {
+						t.Fatal(err)
+					}
+
+					stdout, err := ioutil.ReadFile(filepath.Join(tmpDir, "stdout"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(stdout) == 0 {
+						stderr, _ := ioutil.ReadFile(filepath.Join(tmpDir, "stderr"))
+						t.Fatalf("stdout was empty: stderr: %s", stderr)
+					}
+					if diff := cmp.Diff(string(stdout), "apple\ncherry\n"); diff != "" {
+						t.Fatalf(`syscall.Exec stdout did not match: (-got +want)\n%s`, diff)
+					}
+				})
+			})
+		})
+
 		when("buildpacks provided profile.d scripts", func() {
 			it.Before(func() {
 				if err := ioutil.WriteFile(filepath.Join(tmpDir, "launch", "app", "start"), []byte("#!/usr/bin/env bash\necho hi from app\n"), 0777); err != nil {
@@ -165,7 +451,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 			})
 
 			it("should run them in buildpack order", func() {
-				if err := launcher.Launch("/path/to/launcher", "start"); err != nil {
+				if err := launcher.Launch("/path/to/launcher", []string{"start"}); err != nil {
 					t.Fatal(err)
 				}
 
@@ -193,7 +479,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 				})
 
 				it("should run them in buildpack order", func() {
-					if err := launcher.Launch("/path/to/launcher", "start"); err != nil {
+					if err := launcher.Launch("/path/to/launcher", []string{"start"}); err != nil {
 						t.Fatal(err)
 					}
 
@@ -221,7 +507,7 @@ func testLauncher(t *testing.T, when spec.G, it spec.S) {
 				})
 
 				it("should source .profile", func() {
-					if err := launcher.Launch("/path/to/launcher", "start"); err != nil {
+					if err := launcher.Launch("/path/to/launcher", []string{"start"}); err != nil {
 						t.Fatal(err)
 					}
 
@@ -0,0 +1,98 @@
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LintOrder checks an order file against the buildpacks in buildpacksDir
+// without running any buildpack. It returns a description of each problem
+// found, or an error if the order file cannot be read.
+func LintOrder(buildpacksDir, orderPath string) ([]string, error) {
+	order, err := readOrderTOML(orderPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := buildpackTOMLFiles(buildpacksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	m := BuildpackMap{}
+	for _, file := range files {
+		ref, bp, err := readBuildpackTOML(file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("malformed %s: %s", file, err))
+			continue
+		}
+		if bp.ID == "" {
+			problems = append(problems, fmt.Sprintf("malformed %s: missing buildpack id", file))
+			continue
+		}
+		m[ref] = bp
+	}
+
+	l := &orderLinter{m: m, checked: map[string]bool{}, problems: problems}
+	l.lint("group", order)
+	return l.problems, nil
+}
+
+type orderLinter struct {
+	m        BuildpackMap
+	checked  map[string]bool
+	problems []string
+}
+
+// lint checks each group in the order, and the orders of any meta-buildpacks
+// they refer to. Groups are described by label and their position.
+func (l *orderLinter) lint(label string, order BuildpackOrder) {
+	for i, group := range order {
+		name := fmt.Sprintf("%s %d", label, i+1)
+		if len(group.Buildpacks) == 0 {
+			l.add("%s is empty", name)
+			continue
+		}
+		seen := map[string]bool{}
+		required := false
+		for _, ref := range group.Buildpacks {
+			if seen[ref.ID] {
+				l.add("%s contains buildpack '%s' more than once", name, ref.ID)
+			}
+			seen[ref.ID] = true
+			required = required || !ref.Optional
+
+			bp, ok := l.m.resolve(ref.ID, ref.Version)
+			if !ok {
+				refName := ref.ID
+				if ref.Version != "" {
+					refName += "@" + ref.Version
+				}
+				l.add("%s refers to unresolved buildpack '%s'", name, refName)
+				continue
+			}
+			if l.checked[bp.Dir] {
+				continue
+			}
+			l.checked[bp.Dir] = true
+			if len(bp.Order) > 0 {
+				l.lint(fmt.Sprintf("buildpack '%s@%s' group", bp.ID, bp.Version), bp.Order)
+				continue
+			}
+			for _, executable := range []string{"detect", "build"} {
+				path := filepath.Join(bp.Dir, "bin", executable)
+				if fi, err := os.Stat(path); err != nil || fi.IsDir() || fi.Mode()&0111 == 0 {
+					l.add("buildpack '%s@%s' is missing executable bin/%s", bp.ID, bp.Version, executable)
+				}
+			}
+		}
+		if !required {
+			l.add("%s has no required buildpacks", name)
+		}
+	}
+}
+
+func (l *orderLinter) add(format string, a ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, a...))
+}
@@ -0,0 +1,99 @@
+package lifecycle_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+)
+
+func TestLint(t *testing.T) {
+	spec.Run(t, "Lint", testLint, spec.Report(report.Terminal{}))
+}
+
+func testLint(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir, buildpacksDir, orderPath string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.lint")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		buildpacksDir = filepath.Join(tmpDir, "buildpacks")
+		orderPath = filepath.Join(tmpDir, "order.toml")
+		mkdir(t,
+			filepath.Join(buildpacksDir, "buildpack1", "1.0.0", "bin"),
+			filepath.Join(buildpacksDir, "buildpack2", "1.0.0", "bin"),
+			filepath.Join(buildpacksDir, "buildpack3", "1.0.0"),
+		)
+		mkBuildpackTOML(t, buildpacksDir, "buildpack1", "buildpack1-name", "1.0.0")
+		mkBuildpackTOML(t, buildpacksDir, "buildpack2", "buildpack2-name", "1.0.0")
+		mkfile(t, "#!/bin/sh",
+			filepath.Join(buildpacksDir, "buildpack1", "1.0.0", "bin", "detect"),
+			filepath.Join(buildpacksDir, "buildpack1", "1.0.0", "bin", "build"),
+			filepath.Join(buildpacksDir, "buildpack2", "1.0.0", "bin", "detect"),
+		)
+		mkfile(t, "[buildpack", filepath.Join(buildpacksDir, "buildpack3", "1.0.0", "buildpack.toml"))
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when(".LintOrder", func() {
+		it("should return no problems for a valid order", func() {
+			mkfile(t, `groups = [{ buildpacks = [{id = "buildpack1"}, {id = "buildpack2", optional = true}] }]`, orderPath)
+			mkfile(t, "#!/bin/sh", filepath.Join(buildpacksDir, "buildpack2", "1.0.0", "bin", "build"))
+			if err := os.RemoveAll(filepath.Join(buildpacksDir, "buildpack3")); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			problems, err := lifecycle.LintOrder(buildpacksDir, orderPath)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if len(problems) > 0 {
+				t.Fatalf("Unexpected problems: %s\n", problems)
+			}
+		})
+
+		it("should return each problem found", func() {
+			mkfile(t, `groups = [
+				{ buildpacks = [{id = "buildpack1"}, {id = "buildpack1", version = "^1"}] },
+				{ buildpacks = [{id = "buildpack2", optional = true}] },
+				{ buildpacks = [{id = "buildpack1", version = "2.0.0"}, {id = "buildpakc2"}] },
+				{ buildpacks = [] },
+			]`, orderPath)
+			problems, err := lifecycle.LintOrder(buildpacksDir, orderPath)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(problems[1:], []string{
+				"group 1 contains buildpack 'buildpack1' more than once",
+				"buildpack 'buildpack2@1.0.0' is missing executable bin/build",
+				"group 2 has no required buildpacks",
+				"group 3 refers to unresolved buildpack 'buildpack1@2.0.0'",
+				"group 3 refers to unresolved buildpack 'buildpakc2'",
+				"group 4 is empty",
+			}); s != "" {
+				t.Fatalf("Unexpected problems:\n%s\n", s)
+			}
+			if !strings.HasPrefix(problems[0], "malformed "+filepath.Join(buildpacksDir, "buildpack3", "1.0.0", "buildpack.toml")+": ") {
+				t.Fatalf("Unexpected problem: %s\n", problems[0])
+			}
+		})
+
+		it("should return an error when the order file cannot be read", func() {
+			if _, err := lifecycle.LintOrder(buildpacksDir, orderPath); err == nil {
+				t.Fatal("Expected error.\n")
+			}
+		})
+	})
+}
@@ -2,77 +2,292 @@ package lifecycle
 
 import (
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
 )
 
 type BuildpackMap map[string]*Buildpack
 
 type buildpackTOML struct {
 	Buildpack struct {
-		ID      string `toml:"id"`
-		Version string `toml:"version"`
-		Name    string `toml:"name"`
+		ID            string `toml:"id"`
+		Version       string `toml:"version"`
+		Name          string `toml:"name"`
+		DetectTimeout string `toml:"detect-timeout"`
 	} `toml:"buildpack"`
+	Stacks []struct {
+		ID string `toml:"id"`
+	} `toml:"stacks"`
+	Order BuildpackOrder `toml:"order"`
 }
 
 func NewBuildpackMap(dir string) (BuildpackMap, error) {
 	buildpacks := BuildpackMap{}
-	glob := filepath.Join(dir, "*", "*", "buildpack.toml")
-	files, err := filepath.Glob(glob)
+	files, err := buildpackTOMLFiles(dir)
 	if err != nil {
 		return nil, err
 	}
 	for _, file := range files {
-		buildpackDir := filepath.Dir(file)
-		_, version := filepath.Split(buildpackDir)
-		var bpTOML buildpackTOML
-		if _, err := toml.DecodeFile(file, &bpTOML); err != nil {
+		ref, bp, err := readBuildpackTOML(file)
+		if err != nil {
 			return nil, err
 		}
-		buildpacks[bpTOML.Buildpack.ID+"@"+version] = &Buildpack{
-			ID:      bpTOML.Buildpack.ID,
-			Version: bpTOML.Buildpack.Version,
-			Name:    bpTOML.Buildpack.Name,
-			Dir:     buildpackDir,
-		}
+		buildpacks[ref] = bp
 	}
 	return buildpacks, nil
 }
 
-func (m BuildpackMap) lookup(l []*Buildpack) []*Buildpack {
+func buildpackTOMLFiles(dir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dir, "*", "*", "buildpack.toml"))
+}
+
+func readBuildpackTOML(file string) (ref string, bp *Buildpack, err error) {
+	buildpackDir := filepath.Dir(file)
+	_, version := filepath.Split(buildpackDir)
+	var bpTOML buildpackTOML
+	if _, err := toml.DecodeFile(file, &bpTOML); err != nil {
+		return "", nil, err
+	}
+	var detectTimeout time.Duration
+	if bpTOML.Buildpack.DetectTimeout != "" {
+		if detectTimeout, err = time.ParseDuration(bpTOML.Buildpack.DetectTimeout); err != nil {
+			return "", nil, errors.Wrapf(err, "parse detect-timeout in %s", file)
+		}
+	}
+	var stacks []string
+	for _, stack := range bpTOML.Stacks {
+		stacks = append(stacks, stack.ID)
+	}
+	return bpTOML.Buildpack.ID + "@" + version, &Buildpack{
+		ID:            bpTOML.Buildpack.ID,
+		Version:       bpTOML.Buildpack.Version,
+		Name:          bpTOML.Buildpack.Name,
+		Dir:           buildpackDir,
+		DetectTimeout: detectTimeout,
+		Stacks:        stacks,
+		Order:         bpTOML.Order,
+	}, nil
+}
+
+// ErrorUnresolvedBuildpacks is returned when buildpack references cannot
+// be found in a BuildpackMap.
+type ErrorUnresolvedBuildpacks struct {
+	Refs []string
+}
+
+func (e *ErrorUnresolvedBuildpacks) Error() string {
+	return "unresolved buildpack references: " + strings.Join(e.Refs, ", ")
+}
+
+// lookup resolves the buildpack references in l, including the references
+// in the orders of any meta-buildpacks. The parents of a meta-buildpack are
+// given by stack to detect cycles.
+func (m BuildpackMap) lookup(l []*Buildpack, unresolved *ErrorUnresolvedBuildpacks, stack ...string) []*Buildpack {
 	out := make([]*Buildpack, 0, len(l))
 	for _, b := range l {
-		ref := b.ID + "@" + b.Version
-		if b.Version == "" {
-			ref += "latest"
-		}
-		if bp, ok := m[ref]; ok {
-			bp := *bp
+		if bp, ok := m.resolve(b.ID, b.Version); ok {
 			bp.Optional = b.Optional
-			out = append(out, &bp)
+			if len(bp.Order) > 0 {
+				ref := bp.ID + "@" + bp.Version
+				if containsString(stack, ref) {
+					unresolved.add(ref + " (cyclic)")
+					continue
+				}
+				order := make(BuildpackOrder, 0, len(bp.Order))
+				for _, g := range bp.Order {
+					order = append(order, BuildpackGroup{
+						Buildpacks: m.lookup(g.Buildpacks, unresolved, append(stack, ref)...),
+					})
+				}
+				bp.Order = order
+			}
+			out = append(out, bp)
+		} else if b.Version == "" {
+			unresolved.add(b.ID)
+		} else {
+			unresolved.add(b.ID + "@" + b.Version)
 		}
 	}
 	return out
 }
 
-func (m BuildpackMap) ReadOrder(orderPath string) (BuildpackOrder, error) {
-	var order struct {
-		Groups BuildpackOrder `toml:"groups"`
+func (e *ErrorUnresolvedBuildpacks) add(ref string) {
+	if !containsString(e.Refs, ref) {
+		e.Refs = append(e.Refs, ref)
 	}
-	if _, err := toml.DecodeFile(orderPath, &order); err != nil {
+}
+
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ErrorUnresolvedBuildpacks) err() error {
+	if len(e.Refs) == 0 {
+		return nil
+	}
+	return e
+}
+
+// resolve returns a copy of the buildpack with the exact version, or else
+// the highest version directory allowed by the version range.
+// An empty version or "latest" allows any version that is not a pre-release,
+// but falls back to a directory named "latest" if no version is found.
+// The copy has the version from its buildpack.toml, or else the version
+// directory, so that the version recorded for it is never "latest".
+func (m BuildpackMap) resolve(id, version string) (*Buildpack, bool) {
+	if version == "" {
+		version = "latest"
+	}
+	constraint := version
+	if version == "latest" {
+		constraint = "*"
+	} else if bp, ok := m[id+"@"+version]; ok {
+		return resolved(bp, version), true
+	} else if bp, dir, ok := m.withVersion(id, version); ok {
+		return resolved(bp, dir), true
+	}
+	r, err := parseSemverRange(constraint)
+	if err != nil {
+		return nil, false
+	}
+	var (
+		best    *Buildpack
+		bestVer semver
+		bestDir string
+	)
+	for ref, bp := range m {
+		i := strings.LastIndex(ref, "@")
+		if i < 0 || ref[:i] != id {
+			continue
+		}
+		v, err := parseSemver(ref[i+1:])
+		if err != nil || !r.allows(v) {
+			continue
+		}
+		if n := v.compare(bestVer); best == nil || n > 0 || (n == 0 && ref[i+1:] < bestDir) {
+			best, bestVer, bestDir = bp, v, ref[i+1:]
+		}
+	}
+	if best == nil {
+		if bp, ok := m[id+"@latest"]; ok && version == "latest" {
+			return resolved(bp, "latest"), true
+		}
+		return nil, false
+	}
+	return resolved(best, bestDir), true
+}
+
+// withVersion returns the buildpack whose buildpack.toml has the version,
+// and its version directory. This finds the buildpacks in directories that
+// are not named by their version, such as "latest".
+func (m BuildpackMap) withVersion(id, version string) (bp *Buildpack, dir string, ok bool) {
+	for ref, b := range m {
+		i := strings.LastIndex(ref, "@")
+		if i < 0 || ref[:i] != id || b.Version != version {
+			continue
+		}
+		if !ok || ref[i+1:] < dir {
+			bp, dir, ok = b, ref[i+1:], true
+		}
+	}
+	return bp, dir, ok
+}
+
+// resolved returns a copy of the buildpack in the version directory dir.
+func resolved(bp *Buildpack, dir string) *Buildpack {
+	out := *bp
+	if out.Version == "" && dir != "latest" {
+		out.Version = dir
+	}
+	return &out
+}
+
+func (m BuildpackMap) ReadOrder(orderPath string) (BuildpackOrder, error) {
+	order, err := readOrderTOML(orderPath)
+	if err != nil {
 		return nil, err
 	}
 
 	var groups BuildpackOrder
-	for _, g := range order.Groups {
+	unresolved := &ErrorUnresolvedBuildpacks{}
+	for _, g := range order {
 		groups = append(groups, BuildpackGroup{
-			Buildpacks: m.lookup(g.Buildpacks),
+			Buildpacks: m.lookup(g.Buildpacks, unresolved),
 		})
 	}
+	if err := unresolved.err(); err != nil {
+		return nil, err
+	}
 	return groups, nil
 }
 
+// ForStack returns the order without the buildpacks that do not support
+// the stack, and the names of the buildpacks it leaves out. A group is left
+// out if any required buildpack in it is, and a meta-buildpack is left out
+// if all of its groups are.
+func (bo BuildpackOrder) ForStack(id string) (BuildpackOrder, []string) {
+	var (
+		order    BuildpackOrder
+		excluded []string
+	)
+	for _, g := range bo {
+		group, ok := g.forStack(id, &excluded)
+		if ok {
+			order = append(order, group)
+		}
+	}
+	return order, excluded
+}
+
+func (bg BuildpackGroup) forStack(id string, excluded *[]string) (BuildpackGroup, bool) {
+	var group BuildpackGroup
+	ok := true
+	for _, bp := range bg.Buildpacks {
+		supported := bp.SupportsStack(id)
+		if supported && len(bp.Order) > 0 {
+			order, metaExcluded := bp.Order.ForStack(id)
+			for _, name := range metaExcluded {
+				addString(excluded, name)
+			}
+			meta := *bp
+			meta.Order = order
+			bp, supported = &meta, len(order) > 0
+		}
+		if !supported {
+			addString(excluded, bp.Name)
+			ok = ok && bp.Optional
+			continue
+		}
+		group.Buildpacks = append(group.Buildpacks, bp)
+	}
+	return group, ok && len(group.Buildpacks) > 0
+}
+
+func addString(l *[]string, s string) {
+	if !containsString(*l, s) {
+		*l = append(*l, s)
+	}
+}
+
+// readOrderTOML returns the unresolved buildpack references in an order file.
+func readOrderTOML(orderPath string) (BuildpackOrder, error) {
+	var order struct {
+		Groups BuildpackOrder `toml:"groups"`
+	}
+	if _, err := toml.DecodeFile(orderPath, &order); err != nil {
+		return nil, err
+	}
+	return order.Groups, nil
+}
+
 func (g *BuildpackGroup) Write(path string) error {
 	data := struct {
 		Buildpacks []*Buildpack `toml:"buildpacks"`
@@ -87,6 +302,10 @@ func (m BuildpackMap) ReadGroup(path string) (*BuildpackGroup, error) {
 	if _, err := toml.DecodeFile(path, &group); err != nil {
 		return nil, err
 	}
-	group.Buildpacks = m.lookup(group.Buildpacks)
+	unresolved := &ErrorUnresolvedBuildpacks{}
+	group.Buildpacks = m.lookup(group.Buildpacks, unresolved)
+	if err := unresolved.err(); err != nil {
+		return nil, err
+	}
 	return &group, nil
 }
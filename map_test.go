@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/sclevine/spec"
@@ -66,6 +67,59 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf("Unexpected map:\n%s\n", s)
 			}
 		})
+
+		it("should read the detect timeout from buildpack.toml", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, "[buildpack]\nid = \"buildpack1\"\ndetect-timeout = \"1m30s\"\n",
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(m["buildpack1@version1"].DetectTimeout, 90*time.Second); s != "" {
+				t.Fatalf("Unexpected timeout:\n%s\n", s)
+			}
+		})
+
+		it("should read the supported stacks from buildpack.toml", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, "[buildpack]\nid = \"buildpack1\"\n[[stacks]]\nid = \"stack1\"\n[[stacks]]\nid = \"stack2\"\n",
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(tmpDir)
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if s := cmp.Diff(m["buildpack1@version1"].Stacks, []string{"stack1", "stack2"}); s != "" {
+				t.Fatalf("Unexpected stacks:\n%s\n", s)
+			}
+		})
+
+		it("should return an error when the detect timeout is invalid", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.test")
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			mkdir(t, filepath.Join(tmpDir, "buildpack1", "version1"))
+			mkfile(t, "[buildpack]\nid = \"buildpack1\"\ndetect-timeout = \"soon\"\n",
+				filepath.Join(tmpDir, "buildpack1", "version1", "buildpack.toml"),
+			)
+			if _, err := lifecycle.NewBuildpackMap(tmpDir); err == nil {
+				t.Fatal("Expected error.\n")
+			}
+		})
 	})
 
 	when("#ReadOrder", func() {
@@ -97,11 +151,182 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 				t.Fatal(err)
 			}
 			if s := cmp.Diff(actual, lifecycle.BuildpackOrder{
-				{Buildpacks: []*lifecycle.Buildpack{{Name: "buildpack1-1.1"}, {Name: "buildpack2", Optional: true}}},
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "buildpack1-1.1", Version: "version1.1"}, {Name: "buildpack2", Optional: true}}},
+			}); s != "" {
+				t.Fatalf("Unexpected list:\n%s\n", s)
+			}
+		})
+		it("should resolve version ranges and latest to the highest matching version", func() {
+			m := lifecycle.BuildpackMap{
+				"buildpack1@1.2.0":      {Name: "buildpack1-1.2.0", Version: "1.2.0"},
+				"buildpack1@1.3.1":      {Name: "buildpack1-1.3.1", Version: "1.3.1"},
+				"buildpack1@2.0.0":      {Name: "buildpack1-2.0.0", Version: "2.0.0"},
+				"buildpack1@2.1.0-rc.1": {Name: "buildpack1-2.1.0-rc.1", Version: "2.1.0-rc.1"},
+				"buildpack1@version3":   {Name: "buildpack1-version3", Version: "version3"},
+				"buildpack2@v0.9.0":     {Name: "buildpack2-0.9.0", Version: "0.9.0"},
+				"buildpack2@latest":     {Name: "buildpack2-latest"},
+				"buildpack3@latest":     {Name: "buildpack3-latest"},
+			}
+			mkfile(t, `groups = [{ buildpacks = [
+				{id = "buildpack1", version = "^1.2"},
+				{id = "buildpack1", version = "~1.2"},
+				{id = "buildpack1", version = ">=2.0 <3"},
+				{id = "buildpack1", version = ">=2.1.0-rc.1"},
+				{id = "buildpack1", version = "1.x || ^3"},
+				{id = "buildpack1"},
+				{id = "buildpack2", version = "latest"},
+				{id = "buildpack3"},
+			] }]`,
+				filepath.Join(tmpDir, "order.toml"),
+			)
+			actual, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := cmp.Diff(actual, lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{
+					{Name: "buildpack1-1.3.1", Version: "1.3.1"},
+					{Name: "buildpack1-1.2.0", Version: "1.2.0"},
+					{Name: "buildpack1-2.0.0", Version: "2.0.0"},
+					{Name: "buildpack1-2.1.0-rc.1", Version: "2.1.0-rc.1"},
+					{Name: "buildpack1-1.3.1", Version: "1.3.1"},
+					{Name: "buildpack1-2.0.0", Version: "2.0.0"},
+					{Name: "buildpack2-0.9.0", Version: "0.9.0"},
+					{Name: "buildpack3-latest"},
+				}},
 			}); s != "" {
 				t.Fatalf("Unexpected list:\n%s\n", s)
 			}
 		})
+		it("should return an error listing unresolved references", func() {
+			m := lifecycle.BuildpackMap{
+				"buildpack1@version1.1": {Name: "buildpack1-1.1"},
+			}
+			mkfile(t, `groups = [
+				{ buildpacks = [{id = "buildpack1", version = "version1.1"}, {id = "buildpakc2", optional = true}] },
+				{ buildpacks = [{id = "buildpack1", version = "version1.2"}, {id = "buildpakc2"}] },
+			]`,
+				filepath.Join(tmpDir, "order.toml"),
+			)
+			_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+			if err, ok := err.(*lifecycle.ErrorUnresolvedBuildpacks); !ok {
+				t.Fatalf("Incorrect error: %s\n", err)
+			} else if s := cmp.Diff(err.Refs, []string{"buildpakc2", "buildpack1@version1.2"}); s != "" {
+				t.Fatalf("Unexpected refs:\n%s\n", s)
+			}
+		})
+		it("should resolve the orders of meta-buildpacks", func() {
+			mkdir(t,
+				filepath.Join(tmpDir, "buildpacks", "meta", "1.0.0"),
+				filepath.Join(tmpDir, "buildpacks", "buildpack1", "1.0.0"),
+				filepath.Join(tmpDir, "buildpacks", "buildpack2", "1.0.0"),
+			)
+			mkfile(t, "[buildpack]\nid = \"meta\"\nversion = \"1.0.0\"\n"+
+				"[[order]]\nbuildpacks = [{id = \"buildpack1\"}, {id = \"buildpack2\", optional = true}]\n"+
+				"[[order]]\nbuildpacks = [{id = \"buildpack2\", version = \"^1\"}]\n",
+				filepath.Join(tmpDir, "buildpacks", "meta", "1.0.0", "buildpack.toml"),
+			)
+			mkBuildpackTOML(t, filepath.Join(tmpDir, "buildpacks"), "buildpack1", "buildpack1-name", "1.0.0")
+			mkBuildpackTOML(t, filepath.Join(tmpDir, "buildpacks"), "buildpack2", "buildpack2-name", "1.0.0")
+			mkfile(t, `groups = [{ buildpacks = [{id = "meta", optional = true}] }]`,
+				filepath.Join(tmpDir, "order.toml"),
+			)
+			m, err := lifecycle.NewBuildpackMap(filepath.Join(tmpDir, "buildpacks"))
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			actual, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+			if err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			bp := func(id string, optional bool) *lifecycle.Buildpack {
+				return &lifecycle.Buildpack{
+					ID:       id,
+					Name:     id + "-name",
+					Version:  "1.0.0",
+					Optional: optional,
+					Dir:      filepath.Join(tmpDir, "buildpacks", id, "1.0.0"),
+				}
+			}
+			if s := cmp.Diff(actual, lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{
+					ID:       "meta",
+					Version:  "1.0.0",
+					Optional: true,
+					Dir:      filepath.Join(tmpDir, "buildpacks", "meta", "1.0.0"),
+					Order: lifecycle.BuildpackOrder{
+						{Buildpacks: []*lifecycle.Buildpack{bp("buildpack1", false), bp("buildpack2", true)}},
+						{Buildpacks: []*lifecycle.Buildpack{bp("buildpack2", false)}},
+					},
+				}}},
+			}); s != "" {
+				t.Fatalf("Unexpected order:\n%s\n", s)
+			}
+		})
+
+		it("should return an error when meta-buildpacks refer to themselves", func() {
+			m := lifecycle.BuildpackMap{
+				"meta1@1.0.0": {ID: "meta1", Version: "1.0.0", Order: lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{{ID: "meta2"}}},
+				}},
+				"meta2@1.0.0": {ID: "meta2", Version: "1.0.0", Order: lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{{ID: "meta1"}}},
+				}},
+			}
+			mkfile(t, `groups = [{ buildpacks = [{id = "meta1"}] }]`,
+				filepath.Join(tmpDir, "order.toml"),
+			)
+			_, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+			if err, ok := err.(*lifecycle.ErrorUnresolvedBuildpacks); !ok {
+				t.Fatalf("Incorrect error: %s\n", err)
+			} else if s := cmp.Diff(err.Refs, []string{"meta1@1.0.0 (cyclic)"}); s != "" {
+				t.Fatalf("Unexpected refs:\n%s\n", s)
+			}
+		})
+	})
+
+	when("#ForStack", func() {
+		it("should leave out buildpacks and groups that do not support the stack", func() {
+			bp := func(name string, optional bool, stacks ...string) *lifecycle.Buildpack {
+				return &lifecycle.Buildpack{Name: name, Optional: optional, Stacks: stacks}
+			}
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp("bp1", false, "other-stack"), bp("bp2", false)}},
+				{Buildpacks: []*lifecycle.Buildpack{bp("bp3", true, "other-stack"), bp("bp4", false, "some-stack")}},
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "meta", Order: lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{bp("bp5", false, "other-stack")}},
+					{Buildpacks: []*lifecycle.Buildpack{bp("bp6", false, "*")}},
+				}}}},
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "meta-unsupported", Order: lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{bp("bp7", false, "other-stack")}},
+				}}}},
+			}
+			actual, excluded := order.ForStack("some-stack")
+			if s := cmp.Diff(actual, lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{bp("bp4", false, "some-stack")}},
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "meta", Order: lifecycle.BuildpackOrder{
+					{Buildpacks: []*lifecycle.Buildpack{bp("bp6", false, "*")}},
+				}}}},
+			}); s != "" {
+				t.Fatalf("Unexpected order:\n%s\n", s)
+			}
+			if s := cmp.Diff(excluded, []string{"bp1", "bp3", "bp5", "bp7", "meta-unsupported"}); s != "" {
+				t.Fatalf("Unexpected excluded buildpacks:\n%s\n", s)
+			}
+		})
+
+		it("should leave out no buildpacks without a stack", func() {
+			order := lifecycle.BuildpackOrder{
+				{Buildpacks: []*lifecycle.Buildpack{{Name: "bp1", Stacks: []string{"other-stack"}}}},
+			}
+			actual, excluded := order.ForStack("")
+			if s := cmp.Diff(actual, order); s != "" {
+				t.Fatalf("Unexpected order:\n%s\n", s)
+			}
+			if len(excluded) != 0 {
+				t.Fatalf("Unexpected excluded buildpacks: %v\n", excluded)
+			}
+		})
 	})
 
 	when("#ReadGroup", func() {
@@ -133,11 +358,52 @@ func testMap(t *testing.T, when spec.G, it spec.S) {
 				t.Fatal(err)
 			}
 			if s := cmp.Diff(actual, &lifecycle.BuildpackGroup{
-				Buildpacks: []*lifecycle.Buildpack{{Name: "buildpack1-1.1"}, {Name: "buildpack2", Optional: true}},
+				Buildpacks: []*lifecycle.Buildpack{{Name: "buildpack1-1.1", Version: "version1.1"}, {Name: "buildpack2", Optional: true}},
 			}); s != "" {
 				t.Fatalf("Unexpected list:\n%s\n", s)
 			}
 		})
+		it("should read a group written for an order with latest-only buildpacks", func() {
+			mkdir(t, filepath.Join(tmpDir, "buildpacks", "com.ex", "latest"))
+			mkfile(t, fmt.Sprintf(buildpackTOML, "com.ex", "com.ex-name", "0.0.1"),
+				filepath.Join(tmpDir, "buildpacks", "com.ex", "latest", "buildpack.toml"),
+			)
+			mkfile(t, `groups = [{ buildpacks = [{id = "com.ex"}] }]`, filepath.Join(tmpDir, "order.toml"))
+			m, err := lifecycle.NewBuildpackMap(filepath.Join(tmpDir, "buildpacks"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			order, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := order[0].Write(filepath.Join(tmpDir, "group.toml")); err != nil {
+				t.Fatal(err)
+			}
+			actual, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := cmp.Diff(actual, &order[0]); s != "" {
+				t.Fatalf("Unexpected group:\n%s\n", s)
+			}
+			if s := cmp.Diff(actual.Buildpacks[0].Version, "0.0.1"); s != "" {
+				t.Fatalf("Unexpected version:\n%s\n", s)
+			}
+		})
+
+		it("should return an error listing unresolved references", func() {
+			m := lifecycle.BuildpackMap{}
+			mkfile(t, `buildpacks = [{id = "buildpack1", version = "version1.1"}]`,
+				filepath.Join(tmpDir, "group.toml"),
+			)
+			_, err := m.ReadGroup(filepath.Join(tmpDir, "group.toml"))
+			if err, ok := err.(*lifecycle.ErrorUnresolvedBuildpacks); !ok {
+				t.Fatalf("Incorrect error: %s\n", err)
+			} else if s := cmp.Diff(err.Error(), "unresolved buildpack references: buildpack1@version1.1"); s != "" {
+				t.Fatalf("Unexpected error:\n%s\n", s)
+			}
+		})
 	})
 
 	when("#Write", func() {
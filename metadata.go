@@ -1,9 +1,11 @@
 package lifecycle
 
 const (
-	MetadataLabel = "io.buildpacks.lifecycle.metadata"
-	EnvLaunchDir  = "PACK_LAUNCH_DIR"
-	EnvAppDir     = "PACK_APP_DIR"
+	MetadataLabel      = "io.buildpacks.lifecycle.metadata"
+	CacheMetadataLabel = "io.buildpacks.lifecycle.cache.metadata"
+	StackIDLabel       = "io.buildpacks.stack.id"
+	EnvLaunchDir       = "PACK_LAUNCH_DIR"
+	EnvAppDir          = "PACK_APP_DIR"
 )
 
 type AppImageMetadata struct {
@@ -36,3 +38,7 @@ type RunImageMetadata struct {
 	TopLayer string `json:"topLayer"`
 	SHA      string `json:"sha"`
 }
+
+type CacheMetadata struct {
+	Buildpacks []BuildpackMetadata `json:"buildpacks"`
+}
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// PlanMerge selects how plan entries with the same name are combined.
+type PlanMerge int
+
+const (
+	// MergeReplace replaces earlier entries with later entries.
+	MergeReplace PlanMerge = iota
+	// MergeDeep merges tables recursively and appends arrays.
+	// Later values win conflicting scalar keys, which are reported as warnings.
+	MergeDeep
+	// MergeDeepStrict merges like MergeDeep, but conflicting scalar keys are errors.
+	MergeDeepStrict
+)
+
+func ParsePlanMerge(s string) (PlanMerge, error) {
+	switch s {
+	case "", "replace":
+		return MergeReplace, nil
+	case "deep":
+		return MergeDeep, nil
+	case "deep-strict":
+		return MergeDeepStrict, nil
+	}
+	return MergeReplace, fmt.Errorf("invalid plan merge strategy '%s'", s)
+}
+
+// merge adds the entry src to the entries in dst under name, returning
+// a description of each conflicting scalar key.
+func (m PlanMerge) merge(dst map[string]interface{}, name string, src interface{}) []string {
+	if m == MergeReplace {
+		dst[name] = src
+		return nil
+	}
+	var conflicts []string
+	dst[name] = mergeValue(name, dst[name], src, &conflicts)
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// mergeEntry is merge for the entries of a Plan.
+func (m PlanMerge) mergeEntry(plan Plan, name string, entry map[string]interface{}) []string {
+	existing, ok := plan[name]
+	if !ok || m == MergeReplace {
+		plan[name] = entry
+		return nil
+	}
+	var conflicts []string
+	plan[name] = mergeValue(name, existing, entry, &conflicts).(map[string]interface{})
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func mergeValue(path string, dst, src interface{}, conflicts *[]string) interface{} {
+	if dst == nil {
+		return copyValue(src)
+	}
+	if dstMap, ok := tomlTable(dst); ok {
+		if srcMap, ok := tomlTable(src); ok {
+			out := map[string]interface{}{}
+			for k, v := range dstMap {
+				out[k] = v
+			}
+			for k, v := range srcMap {
+				out[k] = mergeValue(path+"."+k, out[k], v, conflicts)
+			}
+			return out
+		}
+	}
+	dstVal, srcVal := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if dstVal.Kind() == reflect.Slice && srcVal.Kind() == reflect.Slice {
+		if dstVal.Type() == srcVal.Type() {
+			out := reflect.MakeSlice(dstVal.Type(), 0, dstVal.Len()+srcVal.Len())
+			return reflect.AppendSlice(reflect.AppendSlice(out, dstVal), srcVal).Interface()
+		}
+		var out []interface{}
+		for _, v := range []reflect.Value{dstVal, srcVal} {
+			for i := 0; i < v.Len(); i++ {
+				out = append(out, v.Index(i).Interface())
+			}
+		}
+		return out
+	}
+	if !reflect.DeepEqual(dst, src) {
+		*conflicts = append(*conflicts, fmt.Sprintf("'%s' is both %v and %v", path, dst, src))
+	}
+	return copyValue(src)
+}
+
+func copyValue(v interface{}) interface{} {
+	if m, ok := tomlTable(v); ok {
+		out := map[string]interface{}{}
+		for k, v := range m {
+			out[k] = copyValue(v)
+		}
+		return out
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		out := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out.Index(i).Set(reflect.ValueOf(copyValue(rv.Index(i).Interface())))
+		}
+		return out.Interface()
+	}
+	return v
+}
+
+func tomlTable(v interface{}) (map[string]interface{}, bool) {
+	m, ok := v.(map[string]interface{})
+	return m, ok
+}
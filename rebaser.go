@@ -0,0 +1,82 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+
+	"github.com/buildpack/lifecycle/img"
+)
+
+type Rebaser struct {
+	// OldBaseImage, if set, is the run image that the image was built on.
+	// Its digest must match the run image SHA in the image metadata.
+	OldBaseImage v1.Image
+	Out, Err     io.Writer
+}
+
+func (r *Rebaser) Rebase(origImage, newBaseImage v1.Image) (v1.Image, error) {
+	var metadata AppImageMetadata
+	newImage, err := img.Rebase(origImage, newBaseImage, func(labels map[string]string) (v1.Image, error) {
+		label, ok := labels[MetadataLabel]
+		if !ok {
+			return nil, fmt.Errorf("image is missing label '%s'", MetadataLabel)
+		}
+		if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+			return nil, errors.Wrap(err, "unmarshal metadata")
+		}
+		if metadata.RunImage.TopLayer == "" {
+			return nil, fmt.Errorf("metadata is missing run image top layer")
+		}
+		if err := r.checkOldBase(metadata.RunImage.SHA); err != nil {
+			return nil, err
+		}
+		topLayer, err := v1.NewHash(metadata.RunImage.TopLayer)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse run image top layer")
+		}
+		return img.Base(origImage, topLayer)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "rebase")
+	}
+	oldBaseSHA := metadata.RunImage.SHA
+
+	if err := addRunImageMetadata(newBaseImage, &metadata); err != nil {
+		return nil, err
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "get encoded metadata")
+	}
+	newImage, err = img.Label(newImage, MetadataLabel, string(metadataJSON))
+	if err != nil {
+		return nil, errors.Wrap(err, "set metadata label")
+	}
+	fmt.Fprintf(r.Out, "rebased from run image %s to %s\n", oldBaseSHA, metadata.RunImage.SHA)
+	return newImage, nil
+}
+
+func (r *Rebaser) checkOldBase(sha string) error {
+	if sha == "" {
+		fmt.Fprintln(r.Err, "Warning: metadata is missing run image SHA")
+		return nil
+	}
+	if _, err := v1.NewHash(sha); err != nil {
+		return errors.Wrap(err, "parse run image SHA")
+	}
+	if r.OldBaseImage == nil {
+		return nil
+	}
+	digest, err := r.OldBaseImage.Digest()
+	if err != nil {
+		return errors.Wrap(err, "find old run image digest")
+	}
+	if digest.String() != sha {
+		return fmt.Errorf("image was built on run image %s, not %s", sha, digest)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/img"
+)
+
+func TestRebaser(t *testing.T) {
+	spec.Run(t, "Rebaser", testRebaser, spec.Report(report.Terminal{}))
+}
+
+func testRebaser(t *testing.T, when spec.G, it spec.S) {
+	var (
+		rebaser        *lifecycle.Rebaser
+		stdout, stderr *bytes.Buffer
+		oldBase        v1.Image
+		newBase        v1.Image
+		appLayer       v1.Layer
+		origImage      v1.Image
+	)
+
+	it.Before(func() {
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		rebaser = &lifecycle.Rebaser{
+			Out: io.MultiWriter(stdout, it.Out()),
+			Err: io.MultiWriter(stderr, it.Out()),
+		}
+
+		var err error
+		oldBase, err = random.Image(10, 2)
+		assertNil(t, err)
+		newBase, err = random.Image(10, 3)
+		assertNil(t, err)
+		app, err := random.Image(10, 1)
+		assertNil(t, err)
+		appLayers, err := app.Layers()
+		assertNil(t, err)
+		appLayer = appLayers[0]
+
+		origImage, err = mutate.AppendLayers(oldBase, appLayer)
+		assertNil(t, err)
+	})
+
+	when("#Rebase", func() {
+		when("the image has run image metadata", func() {
+			it.Before(func() {
+				topLayer, err := img.TopLayerDiffID(oldBase)
+				assertNil(t, err)
+				oldDigest, err := oldBase.Digest()
+				assertNil(t, err)
+				metadata := lifecycle.AppImageMetadata{
+					App:      lifecycle.AppMetadata{SHA: "sha256:app"},
+					RunImage: lifecycle.RunImageMetadata{TopLayer: topLayer.String(), SHA: oldDigest.String()},
+				}
+				metadataJSON, err := json.Marshal(metadata)
+				assertNil(t, err)
+				origImage, err = img.Label(origImage, lifecycle.MetadataLabel, string(metadataJSON))
+				assertNil(t, err)
+			})
+
+			it("should replace the run image layers", func() {
+				image, err := rebaser.Rebase(origImage, newBase)
+				if err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+
+				expected, err := diffIDs(newBase)
+				assertNil(t, err)
+				appDiffID, err := appLayer.DiffID()
+				assertNil(t, err)
+				expected = append(expected, appDiffID)
+
+				actual, err := diffIDs(image)
+				assertNil(t, err)
+				if s := cmp.Diff(actual, expected); s != "" {
+					t.Fatalf("Unexpected layers:\n%s\n", s)
+				}
+			})
+
+			it("should update the run image metadata", func() {
+				image, err := rebaser.Rebase(origImage, newBase)
+				if err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				data, err := getMetadata(image)
+				assertNil(t, err)
+
+				topLayer, err := img.TopLayerDiffID(newBase)
+				assertNil(t, err)
+				digest, err := newBase.Digest()
+				assertNil(t, err)
+				assertEq(t, data.RunImage.TopLayer, topLayer.String())
+				assertEq(t, data.RunImage.SHA, digest.String())
+				assertEq(t, data.App.SHA, "sha256:app")
+			})
+
+			it("should rebase when the old run image matches the metadata", func() {
+				rebaser.OldBaseImage = oldBase
+				if _, err := rebaser.Rebase(origImage, newBase); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+			})
+
+			it("should return an error when the old run image does not match the metadata", func() {
+				rebaser.OldBaseImage = newBase
+				_, err := rebaser.Rebase(origImage, newBase)
+				if err == nil || !strings.Contains(err.Error(), "image was built on run image sha256:") {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+		})
+
+		when("the run image SHA is invalid", func() {
+			it("should return an error", func() {
+				topLayer, err := img.TopLayerDiffID(oldBase)
+				assertNil(t, err)
+				metadataJSON := `{"runImage": {"topLayer": "` + topLayer.String() + `", "sha": "sha256:old"}}`
+				image, err := img.Label(origImage, lifecycle.MetadataLabel, metadataJSON)
+				assertNil(t, err)
+				_, err = rebaser.Rebase(image, newBase)
+				if err == nil || !strings.Contains(err.Error(), "parse run image SHA") {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+		})
+
+		when("the image is missing metadata", func() {
+			it("should return an error", func() {
+				_, err := rebaser.Rebase(origImage, newBase)
+				if err == nil || !strings.Contains(err.Error(), lifecycle.MetadataLabel) {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+		})
+
+		when("the run image top layer is not in the image", func() {
+			it("should return an error", func() {
+				metadataJSON := `{"runImage": {"topLayer": "sha256:` + strings.Repeat("0", 64) + `"}}`
+				image, err := img.Label(origImage, lifecycle.MetadataLabel, metadataJSON)
+				assertNil(t, err)
+				if _, err := rebaser.Rebase(image, newBase); err == nil {
+					t.Fatal("Expected error.\n")
+				}
+			})
+		})
+	})
+}
+
+func diffIDs(image v1.Image) ([]v1.Hash, error) {
+	layers, err := image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	var out []v1.Hash
+	for _, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, diffID)
+	}
+	return out, nil
+}
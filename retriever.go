@@ -0,0 +1,113 @@
+package lifecycle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/idtools"
+	"github.com/pkg/errors"
+)
+
+type Retriever struct {
+	Buildpacks []*Buildpack
+	Out, Err   io.Writer
+	UID, GID   int
+}
+
+func (r *Retriever) Retrieve(cacheDir string, cacheStore CacheStore) error {
+	metadata, err := cacheStore.RetrieveMetadata()
+	if err != nil {
+		fmt.Fprintf(r.Err, "Warning: skipping cache restore, cache metadata could not be read: %s\n", err)
+		return nil
+	}
+	buildpacks := r.buildpacks()
+	for _, bpMetadata := range metadata.Buildpacks {
+		buildpack, ok := buildpacks[bpMetadata.ID]
+		if !ok {
+			continue
+		}
+		if buildpack.Version != bpMetadata.Version {
+			fmt.Fprintf(r.Out, "skipping cache for buildpack '%s', cached by version %s\n", bpMetadata.ID, bpMetadata.Version)
+			continue
+		}
+		for layerName, layer := range bpMetadata.Layers {
+			path := filepath.Join(cacheDir, buildpack.EscapedID(), layerName+".toml")
+			if ok, err := layerDataMatches(path, layer.Data); err != nil {
+				return errors.Wrapf(err, "read metadata for cache layer '%s/%s'", bpMetadata.ID, layerName)
+			} else if !ok {
+				fmt.Fprintf(r.Out, "skipping cache layer '%s/%s', metadata does not match\n", bpMetadata.ID, layerName)
+				continue
+			}
+			fmt.Fprintf(r.Out, "restoring cache layer '%s/%s' with SHA %s\n", bpMetadata.ID, layerName, layer.SHA)
+			if err := r.retrieveLayer(cacheDir, cacheStore, layer.SHA); err != nil {
+				return errors.Wrapf(err, "retrieve cache layer '%s/%s'", bpMetadata.ID, layerName)
+			}
+			if layer.Data == nil {
+				continue
+			}
+			if err := WriteTOML(path, layer.Data); err != nil {
+				return errors.Wrapf(err, "write metadata for cache layer '%s/%s'", bpMetadata.ID, layerName)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Retriever) retrieveLayer(cacheDir string, cacheStore CacheStore, sha string) error {
+	rc, err := cacheStore.RetrieveLayer(sha)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tarOptions := &archive.TarOptions{NoLchown: true}
+	if r.UID > 0 && r.GID > 0 {
+		tarOptions = &archive.TarOptions{
+			ChownOpts: &idtools.Identity{
+				UID: r.UID,
+				GID: r.GID,
+			},
+		}
+	}
+	if err := archive.UntarUncompressed(rc, cacheDir, tarOptions); err != nil {
+		return errors.Wrapf(err, "extract layer %s", sha)
+	}
+	return nil
+}
+
+func (r *Retriever) buildpacks() map[string]*Buildpack {
+	buildpacks := make(map[string]*Buildpack, len(r.Buildpacks))
+	for _, b := range r.Buildpacks {
+		buildpacks[b.ID] = b
+	}
+	return buildpacks
+}
+
+// layerDataMatches reports whether the layer metadata at path, if present,
+// is the same as the cached layer metadata. Values are compared as JSON,
+// since cached metadata is stored as JSON and layer metadata as TOML.
+func layerDataMatches(path string, cached interface{}) (bool, error) {
+	var current map[string]interface{}
+	if _, err := toml.DecodeFile(path, &current); os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if len(current) == 0 && cached == nil {
+		return true, nil
+	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return false, err
+	}
+	cachedJSON, err := json.Marshal(cached)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(currentJSON, cachedJSON), nil
+}
@@ -0,0 +1,172 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+	"github.com/buildpack/lifecycle/cache"
+)
+
+func TestRetriever(t *testing.T) {
+	spec.Run(t, "Retriever", testRetriever, spec.Report(report.Terminal{}))
+}
+
+func testRetriever(t *testing.T, when spec.G, it spec.S) {
+	var (
+		retriever      *lifecycle.Retriever
+		volumeCache    *cache.VolumeCache
+		stdout, stderr *bytes.Buffer
+		tmpDir         string
+		cacheDir       string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.retriever")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		cacheDir = filepath.Join(tmpDir, "cache")
+		volumeCache, err = cache.NewVolumeCache(filepath.Join(tmpDir, "volume"))
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		retriever = &lifecycle.Retriever{
+			Buildpacks: []*lifecycle.Buildpack{{ID: "buildpack/1", Version: "1.0"}},
+			Out:        io.MultiWriter(stdout, it.Out()),
+			Err:        io.MultiWriter(stderr, it.Out()),
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#Retrieve", func() {
+		it("should do nothing when the cache is empty", func() {
+			if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+				t.Fatalf("Unexpected cache dir: %s\n", err)
+			}
+		})
+
+		it("should warn and do nothing when the cache metadata is corrupt", func() {
+			mkfile(t, "{", filepath.Join(tmpDir, "volume", "index.json"))
+			if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			if !strings.Contains(stderr.String(), "Warning: skipping cache restore, cache metadata could not be read") {
+				t.Fatalf("Unexpected output: %s\n", stderr)
+			}
+			if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+				t.Fatalf("Unexpected cache dir: %s\n", err)
+			}
+		})
+
+		when("the cache has layers", func() {
+			it.Before(func() {
+				srcDir := filepath.Join(tmpDir, "src")
+				mkdir(t,
+					filepath.Join(srcDir, "buildpack_1", "layer1", "subdir"),
+					filepath.Join(srcDir, "buildpack2", "layer2"),
+					filepath.Join(tmpDir, "artifacts"),
+				)
+				mkfile(t, "file1", filepath.Join(srcDir, "buildpack_1", "layer1", "subdir", "file1"))
+				mkfile(t, "file2", filepath.Join(srcDir, "buildpack2", "layer2", "file2"))
+				mkfile(t, `key = "val"`, filepath.Join(srcDir, "buildpack_1", "layer1.toml"))
+				cacher := &lifecycle.Cacher{
+					Buildpacks:   []*lifecycle.Buildpack{{ID: "buildpack/1", Version: "1.0"}, {ID: "buildpack2", Version: "1.0"}},
+					ArtifactsDir: filepath.Join(tmpDir, "artifacts"),
+					Out:          ioutil.Discard,
+				}
+				if err := cacher.Cache(srcDir, volumeCache); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+			})
+
+			it("should restore layers for buildpacks in the group", func() {
+				if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if b, err := ioutil.ReadFile(filepath.Join(cacheDir, "buildpack_1", "layer1", "subdir", "file1")); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				} else if s := cmp.Diff(string(b), "file1"); s != "" {
+					t.Fatalf("Unexpected file:\n%s\n", s)
+				}
+				if !strings.Contains(stdout.String(), "restoring cache layer 'buildpack/1/layer1' with SHA sha256:") {
+					t.Fatalf("Unexpected output: %s\n", stdout)
+				}
+				if _, err := os.Stat(filepath.Join(cacheDir, "buildpack2")); !os.IsNotExist(err) {
+					t.Fatalf("Unexpected layer restored: %s\n", err)
+				}
+			})
+
+			it("should not restore layers cached by a different buildpack version", func() {
+				retriever.Buildpacks = []*lifecycle.Buildpack{{ID: "buildpack/1", Version: "2.0"}}
+				if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if _, err := os.Stat(filepath.Join(cacheDir, "buildpack_1")); !os.IsNotExist(err) {
+					t.Fatalf("Unexpected layer restored: %s\n", err)
+				}
+			})
+
+			it("should not restore layers whose metadata does not match", func() {
+				mkdir(t, filepath.Join(cacheDir, "buildpack_1"))
+				mkfile(t, `key = "other-val"`, filepath.Join(cacheDir, "buildpack_1", "layer1.toml"))
+				if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if _, err := os.Stat(filepath.Join(cacheDir, "buildpack_1", "layer1")); !os.IsNotExist(err) {
+					t.Fatalf("Unexpected layer restored: %s\n", err)
+				}
+				if b, err := ioutil.ReadFile(filepath.Join(cacheDir, "buildpack_1", "layer1.toml")); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				} else if s := cmp.Diff(string(b), `key = "other-val"`); s != "" {
+					t.Fatalf("Unexpected metadata:\n%s\n", s)
+				}
+				if !strings.Contains(stdout.String(), "skipping cache layer 'buildpack/1/layer1', metadata does not match\n") {
+					t.Fatalf("Unexpected output: %s\n", stdout)
+				}
+			})
+
+			it("should restore layers whose metadata matches", func() {
+				mkdir(t, filepath.Join(cacheDir, "buildpack_1"))
+				mkfile(t, `key = "val"`, filepath.Join(cacheDir, "buildpack_1", "layer1.toml"))
+				if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if _, err := os.Stat(filepath.Join(cacheDir, "buildpack_1", "layer1", "subdir", "file1")); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+			})
+
+			it("should restore layer metadata", func() {
+				if err := retriever.Retrieve(cacheDir, volumeCache); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				var data map[string]interface{}
+				if _, err := toml.DecodeFile(filepath.Join(cacheDir, "buildpack_1", "layer1.toml"), &data); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(data, map[string]interface{}{"key": "val"}); s != "" {
+					t.Fatalf("Unexpected metadata:\n%s\n", s)
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,256 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a semantic version, as used to name buildpack version directories.
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseSemver parses a version such as 1.2.3, v1.2 or 1.2.3-rc.1.
+// Omitted minor and patch numbers are zero. Build metadata is ignored.
+func parseSemver(s string) (semver, error) {
+	v, parts, err := parsePartialSemver(s)
+	if err != nil {
+		return semver{}, err
+	}
+	if parts < 0 {
+		return semver{}, fmt.Errorf("invalid version '%s'", s)
+	}
+	return v, nil
+}
+
+// parsePartialSemver also accepts x and * wildcards in place of numbers.
+// It returns the number of leading numeric parts, or -1 if there are none.
+func parsePartialSemver(s string) (v semver, parts int, err error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "=")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		s, v.pre = s[:i], s[i+1:]
+		if v.pre == "" {
+			return semver{}, 0, fmt.Errorf("invalid version '%s'", s)
+		}
+	}
+	nums := strings.Split(s, ".")
+	if len(nums) > 3 {
+		return semver{}, 0, fmt.Errorf("invalid version '%s'", s)
+	}
+	fields := []*int{&v.major, &v.minor, &v.patch}
+	for i, n := range nums {
+		if n == "x" || n == "X" || n == "*" {
+			if i == 0 {
+				return v, -1, nil
+			}
+			return v, i, nil
+		}
+		if *fields[i], err = strconv.Atoi(n); err != nil || *fields[i] < 0 {
+			return semver{}, 0, fmt.Errorf("invalid version '%s'", s)
+		}
+	}
+	return v, len(nums), nil
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	return s
+}
+
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	}
+	vIDs, oIDs := strings.Split(v.pre, "."), strings.Split(o.pre, ".")
+	for i := 0; i < len(vIDs) && i < len(oIDs); i++ {
+		vn, vErr := strconv.Atoi(vIDs[i])
+		on, oErr := strconv.Atoi(oIDs[i])
+		switch {
+		case vErr == nil && oErr == nil && vn != on:
+			return sign(vn - on)
+		case vErr == nil && oErr != nil:
+			return -1
+		case vErr != nil && oErr == nil:
+			return 1
+		case vErr != nil && oErr != nil && vIDs[i] != oIDs[i]:
+			return strings.Compare(vIDs[i], oIDs[i])
+		}
+	}
+	return sign(len(vIDs) - len(oIDs))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// semverRange is a set of alternative constraints (separated by ||),
+// each of which is a list of comparisons that must all hold.
+type semverRange [][]semverComparison
+
+type semverComparison struct {
+	op string
+	v  semver
+}
+
+// parseSemverRange parses constraints such as ^1.2, ~1.2.3, 1.x,
+// >=2.0 <3, >= 2.0, < 3 or 1.2 || ^2.
+func parseSemverRange(s string) (semverRange, error) {
+	var r semverRange
+	for _, alt := range strings.Split(s, "||") {
+		var cmps []semverComparison
+		for _, field := range semverRangeFields(alt) {
+			c, err := parseSemverComparisons(field)
+			if err != nil {
+				return nil, err
+			}
+			cmps = append(cmps, c...)
+		}
+		if len(cmps) == 0 {
+			return nil, fmt.Errorf("invalid version range '%s'", s)
+		}
+		r = append(r, cmps)
+	}
+	return r, nil
+}
+
+// semverRangeFields splits a list of comparisons, joining each operator
+// that is followed by whitespace to its version.
+func semverRangeFields(s string) []string {
+	var fields []string
+	op := ""
+	for _, field := range strings.Fields(strings.Replace(s, ",", " ", -1)) {
+		if strings.Trim(field, "<>=^~") == "" {
+			op += field
+			continue
+		}
+		fields = append(fields, op+field)
+		op = ""
+	}
+	if op != "" {
+		fields = append(fields, op)
+	}
+	return fields
+}
+
+func parseSemverComparisons(s string) ([]semverComparison, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "^", "~", "="} {
+		if strings.HasPrefix(s, prefix) {
+			op, s = prefix, strings.TrimPrefix(s, prefix)
+			break
+		}
+	}
+	v, parts, err := parsePartialSemver(s)
+	if err != nil {
+		return nil, err
+	}
+	if parts < 0 {
+		if op != "" && op != "=" {
+			return nil, fmt.Errorf("invalid version range '%s%s'", op, s)
+		}
+		return []semverComparison{{">=", semver{}}}, nil
+	}
+	// upper is the lowest version excluded by a partial or caret version
+	upper := semver{major: v.major + 1}
+	if parts == 2 {
+		upper = semver{major: v.major, minor: v.minor + 1}
+	} else if parts == 3 {
+		upper = semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+	}
+	switch op {
+	case "^":
+		switch {
+		case v.major > 0 || parts == 1:
+			upper = semver{major: v.major + 1}
+		case v.minor > 0 || parts == 2:
+			upper = semver{minor: v.minor + 1}
+		default:
+			upper = semver{patch: v.patch + 1}
+		}
+		return []semverComparison{{">=", v}, {"<", upper}}, nil
+	case "~":
+		if parts == 1 {
+			upper = semver{major: v.major + 1}
+		} else {
+			upper = semver{major: v.major, minor: v.minor + 1}
+		}
+		return []semverComparison{{">=", v}, {"<", upper}}, nil
+	case "", "=":
+		if parts == 3 {
+			return []semverComparison{{"=", v}}, nil
+		}
+		return []semverComparison{{">=", v}, {"<", upper}}, nil
+	case ">":
+		if parts < 3 {
+			return []semverComparison{{">=", upper}}, nil
+		}
+	case "<=":
+		if parts < 3 {
+			return []semverComparison{{"<", upper}}, nil
+		}
+	}
+	return []semverComparison{{op, v}}, nil
+}
+
+// allows reports whether v satisfies the range. Pre-release versions are
+// only allowed by comparisons that name a pre-release of the same version.
+func (r semverRange) allows(v semver) bool {
+	for _, cmps := range r {
+		if allowsAll(cmps, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func allowsAll(cmps []semverComparison, v semver) bool {
+	preAllowed := v.pre == ""
+	for _, c := range cmps {
+		if !c.allows(v) {
+			return false
+		}
+		if c.v.pre != "" && c.v.major == v.major && c.v.minor == v.minor && c.v.patch == v.patch {
+			preAllowed = true
+		}
+	}
+	return preAllowed
+}
+
+func (c semverComparison) allows(v semver) bool {
+	n := v.compare(c.v)
+	switch c.op {
+	case ">=":
+		return n >= 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	case "<":
+		return n < 0
+	}
+	return n == 0
+}
@@ -0,0 +1,102 @@
+package lifecycle_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpack/lifecycle"
+)
+
+func TestSemver(t *testing.T) {
+	spec.Run(t, "Semver", testSemver, spec.Report(report.Terminal{}))
+}
+
+func testSemver(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir string
+		m      lifecycle.BuildpackMap
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.test")
+		if err != nil {
+			t.Fatalf("Error: %s\n", err)
+		}
+		m = lifecycle.BuildpackMap{}
+		for _, v := range []string{"0.1.0", "0.1.5", "1.2.0", "1.3.1", "2.0.0", "2.1.0-rc.1", "3.0.0"} {
+			m["buildpack@"+v] = &lifecycle.Buildpack{ID: "buildpack", Version: v}
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	resolve := func(constraint string) (string, error) {
+		t.Helper()
+		mkfile(t, fmt.Sprintf(`groups = [{ buildpacks = [{id = "buildpack", version = %q}] }]`, constraint),
+			filepath.Join(tmpDir, "order.toml"),
+		)
+		order, err := m.ReadOrder(filepath.Join(tmpDir, "order.toml"))
+		if err != nil {
+			return "", err
+		}
+		return order[0].Buildpacks[0].Version, nil
+	}
+
+	when("a version range matches", func() {
+		for _, tc := range []struct{ constraint, version string }{
+			{"1.2.0", "1.2.0"},
+			{"=1.3.1", "1.3.1"},
+			{"v1.3.1", "1.3.1"},
+			{"1.x", "1.3.1"},
+			{"1.2.x", "1.2.0"},
+			{"*", "3.0.0"},
+			{"^1.2", "1.3.1"},
+			{"^0.1", "0.1.5"},
+			{"^0.1.0", "0.1.5"},
+			{"~1.2", "1.2.0"},
+			{"~1", "1.3.1"},
+			{">=2.0 <3", "2.0.0"},
+			{">= 2.0 < 3", "2.0.0"},
+			{">=2.0, <3", "2.0.0"},
+			{">= 2.0, < 3", "2.0.0"},
+			{"> 1.2", "3.0.0"},
+			{"<= 1.2", "1.2.0"},
+			{">=2.1.0-rc.1 <3", "2.1.0-rc.1"},
+			{"1.x || ^3", "3.0.0"},
+			{"4.x || ~ 1.2", "1.2.0"},
+		} {
+			tc := tc
+			it(fmt.Sprintf("should resolve '%s' to %s", tc.constraint, tc.version), func() {
+				version, err := resolve(tc.constraint)
+				if err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if s := cmp.Diff(version, tc.version); s != "" {
+					t.Fatalf("Unexpected version:\n%s\n", s)
+				}
+			})
+		}
+	})
+
+	when("no version matches or the range is invalid", func() {
+		for _, constraint := range []string{"4.x", ">3.0.0", "^0.2", "2.1.0", ">=", ">= x", "1.2.3.4", "1.2.a", "1.2.3-"} {
+			constraint := constraint
+			it(fmt.Sprintf("should not resolve '%s'", constraint), func() {
+				_, err := resolve(constraint)
+				if _, ok := err.(*lifecycle.ErrorUnresolvedBuildpacks); !ok {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+			})
+		}
+	})
+}
@@ -44,6 +44,18 @@ func (mr *MockBuildEnvMockRecorder) AddEnvDir(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEnvDir", reflect.TypeOf((*MockBuildEnv)(nil).AddEnvDir), arg0)
 }
 
+// AddFilteredEnvDir mocks base method
+func (m *MockBuildEnv) AddFilteredEnvDir(arg0 string, arg1 func(string) bool) error {
+	ret := m.ctrl.Call(m, "AddFilteredEnvDir", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddFilteredEnvDir indicates an expected call of AddFilteredEnvDir
+func (mr *MockBuildEnvMockRecorder) AddFilteredEnvDir(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFilteredEnvDir", reflect.TypeOf((*MockBuildEnv)(nil).AddFilteredEnvDir), arg0, arg1)
+}
+
 // AddRootDir mocks base method
 func (m *MockBuildEnv) AddRootDir(arg0 string) error {
 	ret := m.ctrl.Call(m, "AddRootDir", arg0)